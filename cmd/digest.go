@@ -0,0 +1,145 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/mail"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+
+	"github.com/timofurrer/influss/internal/digest"
+	"github.com/timofurrer/influss/internal/store"
+)
+
+type digestConfig struct {
+	schedule   string
+	from       string
+	to         string
+	subject    string
+	format     string
+	tag        string
+	unreadOnly bool
+	limit      int
+	stateFile  string
+	smtp       digest.SMTPConfig
+	smtpTLS    string
+}
+
+// digestFlags registers the flags of the digest, which are shared by the serve and digest commands.
+func (c *Cmd) digestFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.config.digestConfig.schedule, "digest-schedule", "", "the cron-like schedule to send digests on, e.g. `0 8 * * mon` or @daily, digests aren't sent if not set")
+	fs.StringVar(&c.config.digestConfig.from, "digest-from", "", "the sender address of digests")
+	fs.StringVar(&c.config.digestConfig.to, "digest-to", "", "the comma-separated recipient addresses of digests, e.g. a send-to-Kindle address")
+	fs.StringVar(&c.config.digestConfig.subject, "digest-subject", "influss digest", "the subject of digests")
+	fs.StringVar(&c.config.digestConfig.format, "digest-format", string(digest.FormatSummary), "how clips are delivered, one of summary, epub or html")
+	fs.StringVar(&c.config.digestConfig.tag, "digest-tag", "", "only send clips with this tag")
+	fs.BoolVar(&c.config.digestConfig.unreadOnly, "digest-unread-only", true, "only send unread clips")
+	fs.IntVar(&c.config.digestConfig.limit, "digest-limit", 50, "the maximum number of clips in a digest, 0 means no limit")
+	fs.StringVar(&c.config.digestConfig.stateFile, "digest-state-file", "digest-state.json", "the path to the file recording which clips were already sent")
+
+	fs.StringVar(&c.config.digestConfig.smtp.Host, "smtp-host", "", "the SMTP server host")
+	fs.IntVar(&c.config.digestConfig.smtp.Port, "smtp-port", 587, "the SMTP server port")
+	fs.StringVar(&c.config.digestConfig.smtp.Username, "smtp-username", "", "the SMTP username, no authentication is used if not set")
+	fs.StringVar(&c.config.digestConfig.smtp.Password, "smtp-password", "", "the SMTP password")
+	fs.StringVar(&c.config.digestConfig.smtpTLS, "smtp-tls", string(digest.TLSModeStartTLS), "how the SMTP connection is secured, one of starttls, tls or none")
+}
+
+func (c *Cmd) validateDigestFlags() error {
+	cfg := &c.config.digestConfig
+	if cfg.schedule != "" {
+		if _, err := digest.ParseSchedule(cfg.schedule); err != nil {
+			return err
+		}
+	}
+
+	if !slices.Contains(digest.Formats, digest.Format(cfg.format)) {
+		return fmt.Errorf("invalid digest format %q, must be one of %v", cfg.format, digest.Formats)
+	}
+
+	switch digest.TLSMode(cfg.smtpTLS) {
+	case digest.TLSModeStartTLS, digest.TLSModeImplicit, digest.TLSModeNone:
+		cfg.smtp.TLS = digest.TLSMode(cfg.smtpTLS)
+	default:
+		return fmt.Errorf("invalid SMTP TLS mode %q, must be one of starttls, tls or none", cfg.smtpTLS)
+	}
+
+	if cfg.limit < 0 {
+		return errors.New("the digest limit must not be negative")
+	}
+
+	return nil
+}
+
+// validateDigestDelivery validates the flags required to actually send digests.
+func (c *Cmd) validateDigestDelivery() error {
+	cfg := c.config.digestConfig
+	if cfg.smtp.Host == "" {
+		return errors.New("sending digests requires an SMTP host")
+	}
+	if _, err := mail.ParseAddress(cfg.from); err != nil {
+		return fmt.Errorf("invalid digest sender address %q: %w", cfg.from, err)
+	}
+	if cfg.to == "" {
+		return errors.New("sending digests requires at least one recipient")
+	}
+	if _, err := mail.ParseAddressList(cfg.to); err != nil {
+		return fmt.Errorf("invalid digest recipient addresses %q: %w", cfg.to, err)
+	}
+	return nil
+}
+
+func (c *Cmd) validateDigestCommandFlags() error {
+	if err := c.validateDigestFlags(); err != nil {
+		return err
+	}
+	if len(c.args) != 0 {
+		return fmt.Errorf("unexpected arguments %s", strings.Join(c.args, " "))
+	}
+	return c.validateDigestDelivery()
+}
+
+func (c *Cmd) newDigest(s store.Store) *digest.Digest {
+	cfg := c.config.digestConfig
+
+	q := store.Query{}
+	if cfg.tag != "" {
+		q.Tags = []string{cfg.tag}
+	}
+	if cfg.unreadOnly {
+		q.Read = new(bool)
+		q.Starred = new(bool)
+	}
+
+	return digest.New(c.log, digest.Config{
+		From:      cfg.from,
+		To:        splitList(cfg.to),
+		Subject:   cfg.subject,
+		Format:    digest.Format(cfg.format),
+		Query:     q,
+		Limit:     cfg.limit,
+		StateFile: cfg.stateFile,
+	}, s, digest.NewMailer(cfg.smtp))
+}
+
+// runDigest sends a digest right away.
+func (c *Cmd) runDigest() error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	s, err := c.newStore()
+	if err != nil {
+		return fmt.Errorf("failed to create store: %w", err)
+	}
+
+	sent, err := c.newDigest(s).Send(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to send digest: %w", err)
+	}
+	c.log.Info("Sent digest", slog.Int("clips", sent))
+	return nil
+}
@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/timofurrer/influss/internal/epub"
+	"github.com/timofurrer/influss/internal/store"
+)
+
+const epubExportFormat = "epub"
+
+type exportConfig struct {
+	format string
+	output string
+	title  string
+	author string
+	tags   []string
+	since  time.Time
+	until  time.Time
+	unread bool
+	limit  int
+}
+
+func (c *Cmd) exportFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.config.exportConfig.output, "output", "", "the path of the exported file, defaults to influss-<date>.epub")
+	fs.StringVar(&c.config.exportConfig.title, "title", "influss", "the title of the exported book")
+	fs.StringVar(&c.config.exportConfig.author, "author", "", "the author of the exported book")
+	fs.Func("tag", "only export clips with this tag, may be given multiple times", func(s string) error {
+		c.config.exportConfig.tags = append(c.config.exportConfig.tags, s)
+		return nil
+	})
+	fs.Func("since", "only export clips clipped after this date, like 2006-01-02", dateFlag(&c.config.exportConfig.since))
+	fs.Func("until", "only export clips clipped before this date, like 2006-01-02", dateFlag(&c.config.exportConfig.until))
+	fs.BoolVar(&c.config.exportConfig.unread, "unread", false, "only export unread clips")
+	fs.IntVar(&c.config.exportConfig.limit, "limit", 100, "the maximum number of clips to export, 0 means no limit")
+}
+
+func dateFlag(t *time.Time) func(string) error {
+	return func(s string) error {
+		parsed, err := time.Parse(time.DateOnly, s)
+		if err != nil {
+			return fmt.Errorf("invalid date %q, must be like 2006-01-02", s)
+		}
+		*t = parsed
+		return nil
+	}
+}
+
+func (c *Cmd) validateExportFlags() error {
+	if c.config.exportConfig.format != epubExportFormat {
+		return fmt.Errorf("invalid export format %q, must be %s", c.config.exportConfig.format, epubExportFormat)
+	}
+
+	if len(c.args) != 0 {
+		return fmt.Errorf("unexpected arguments %s", strings.Join(c.args, " "))
+	}
+
+	if c.config.exportConfig.limit < 0 {
+		return errors.New("the export limit must not be negative")
+	}
+
+	return nil
+}
+
+func (c *Cmd) runExport() error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	s, err := c.newStore()
+	if err != nil {
+		return fmt.Errorf("failed to create store: %w", err)
+	}
+
+	cfg := c.config.exportConfig
+	q := store.Query{
+		Tags:      cfg.tags,
+		Since:     cfg.since,
+		Until:     cfg.until,
+		Ascending: true,
+		Limit:     cfg.limit,
+	}
+	if cfg.unread {
+		q.Read = new(bool)
+		q.Starred = new(bool)
+	}
+
+	clips, _, err := s.List(ctx, q)
+	if err != nil {
+		return fmt.Errorf("failed to list clips: %w", err)
+	}
+	if len(clips) == 0 {
+		return errors.New("no clips match the filters")
+	}
+
+	b := epub.NewBuilder(c.log, epub.Config{Title: cfg.title, Author: cfg.author})
+	for _, clip := range clips {
+		b.WithClip(clip)
+	}
+
+	output := cfg.output
+	if output == "" {
+		output = fmt.Sprintf("influss-%s.epub", time.Now().Format(time.DateOnly))
+	}
+	f, err := os.Create(output)
+	if err != nil {
+		return fmt.Errorf("failed to create export file: %w", err)
+	}
+	defer f.Close()
+
+	c.log.Info("Exporting ...", slog.String("file", output), slog.Int("clips", len(clips)))
+	if err := b.Write(ctx, f); err != nil {
+		return fmt.Errorf("failed to export clips: %w", err)
+	}
+	c.log.Info("Exported", slog.String("file", output), slog.Int("clips", len(clips)))
+	return nil
+}
@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/timofurrer/influss/internal/importer"
+)
+
+type importConfig struct {
+	format    string
+	rateLimit time.Duration
+}
+
+func (c *Cmd) importFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.config.importConfig.format, "format", "", "the format of the export file, one of pocket, instapaper, wallabag, omnivore or netscape-bookmarks")
+	fs.DurationVar(&c.config.importConfig.rateLimit, "rate-limit", time.Second, "the minimum duration between fetching two entries")
+}
+
+func (c *Cmd) validateImportFlags() error {
+	if !slices.Contains(importer.Formats, importer.Format(c.config.importConfig.format)) {
+		return fmt.Errorf("invalid import format %q, must be one of %v", c.config.importConfig.format, importer.Formats)
+	}
+
+	if len(c.args) != 1 {
+		return errors.New("the import command requires exactly one export file argument")
+	}
+
+	return nil
+}
+
+func (c *Cmd) runImport() error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	s, err := c.newStore()
+	if err != nil {
+		return fmt.Errorf("failed to create store: %w", err)
+	}
+
+	clipper, err := c.newClipper(nil, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create clipper: %w", err)
+	}
+
+	file := c.args[0]
+	f, err := os.Open(file)
+	if err != nil {
+		return fmt.Errorf("failed to open export file: %w", err)
+	}
+	defer f.Close()
+
+	entries, err := importer.Parse(importer.Format(c.config.importConfig.format), f, filepath.Dir(file))
+	if err != nil {
+		return fmt.Errorf("failed to parse export file: %w", err)
+	}
+
+	c.log.Info("Importing ...", slog.String("file", file), slog.Int("entries", len(entries)))
+	imported, err := importer.New(c.log, clipper, s, c.config.importConfig.rateLimit).Import(ctx, entries)
+	if err != nil {
+		return fmt.Errorf("failed to import export file after importing %d entries: %w", imported, err)
+	}
+	c.log.Info("Imported", slog.Int("imported", imported))
+	return nil
+}
@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/timofurrer/influss/internal/inbox"
+)
+
+type inboxConfig struct {
+	listenAddr     string
+	protocol       string
+	mode           string
+	recipients     string
+	allowedSenders string
+	maxMessageSize int64
+}
+
+func (c *Cmd) inboxFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.config.inboxConfig.listenAddr, "mail-listen-addr", "", "the address to receive mail on, e.g. :2525, receiving mail is disabled if not set")
+	fs.StringVar(&c.config.inboxConfig.protocol, "mail-protocol", string(inbox.ProtocolSMTP), "the protocol to receive mail with, one of smtp or lmtp")
+	fs.StringVar(&c.config.inboxConfig.mode, "mail-mode", string(inbox.ModeAuto), "how mail is clipped, one of auto, links or newsletter")
+	fs.StringVar(&c.config.inboxConfig.recipients, "mail-recipients", "", "the comma-separated addresses to accept mail for, e.g. influss@example.com")
+	fs.StringVar(&c.config.inboxConfig.allowedSenders, "mail-allowed-senders", "", "the comma-separated sender addresses or domains like @example.com which may send mail")
+	fs.Int64Var(&c.config.inboxConfig.maxMessageSize, "mail-max-size", 10<<20, "the maximum size of a mail in bytes")
+}
+
+func (c *Cmd) validateInboxFlags() error {
+	cfg := c.config.inboxConfig
+	if cfg.listenAddr == "" {
+		return nil
+	}
+
+	switch inbox.Protocol(cfg.protocol) {
+	case inbox.ProtocolSMTP, inbox.ProtocolLMTP:
+	default:
+		return fmt.Errorf("invalid mail protocol %q, must be one of smtp or lmtp", cfg.protocol)
+	}
+
+	if !slices.Contains(inbox.Modes, inbox.Mode(cfg.mode)) {
+		return fmt.Errorf("invalid mail mode %q, must be one of %v", cfg.mode, inbox.Modes)
+	}
+
+	if len(splitList(cfg.recipients)) == 0 {
+		return errors.New("receiving mail requires at least one recipient address")
+	}
+	if len(splitList(cfg.allowedSenders)) == 0 {
+		return errors.New("receiving mail requires at least one allowed sender")
+	}
+
+	if cfg.maxMessageSize <= 0 {
+		return errors.New("the maximum mail size must be positive")
+	}
+
+	return nil
+}
+
+func (c *Cmd) newInboxConfig() inbox.Config {
+	cfg := c.config.inboxConfig
+	return inbox.Config{
+		Addr:           cfg.listenAddr,
+		Protocol:       inbox.Protocol(cfg.protocol),
+		Mode:           inbox.Mode(cfg.mode),
+		Recipients:     splitList(cfg.recipients),
+		AllowedSenders: splitList(cfg.allowedSenders),
+		MaxMessageSize: cfg.maxMessageSize,
+	}
+}
+
+// splitList splits a comma-separated list and drops empty entries.
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+
+	"github.com/timofurrer/influss/internal/store"
+)
+
+const (
+	migrateStatusAction = "status"
+	migrateUpAction     = "up"
+	migrateDownAction   = "down"
+	migrateToAction     = "to"
+)
+
+type migrateConfig struct {
+	action  string
+	version string
+	dryRun  bool
+}
+
+func (c *Cmd) migrateFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&c.config.migrateConfig.dryRun, "dry-run", false, "only log the migrations which would be applied or rolled back")
+}
+
+func (c *Cmd) validateMigrateFlags() error {
+	if !c.config.useSqlStore {
+		return errors.New("the migrate command requires the sql store")
+	}
+
+	switch c.config.migrateConfig.action {
+	case migrateStatusAction, migrateUpAction, migrateDownAction:
+	case migrateToAction:
+		if _, err := strconv.ParseInt(c.config.migrateConfig.version, 10, 64); err != nil || c.config.migrateConfig.version == "" {
+			return fmt.Errorf("invalid migration version %q, the to action requires the version to migrate to, like migrate to 4", c.config.migrateConfig.version)
+		}
+	default:
+		return fmt.Errorf("invalid migrate action %q, must be one of %s, %s, %s or %s", c.config.migrateConfig.action, migrateStatusAction, migrateUpAction, migrateDownAction, migrateToAction)
+	}
+
+	if len(c.args) != 0 {
+		return fmt.Errorf("unexpected arguments %s", strings.Join(c.args, " "))
+	}
+
+	return nil
+}
+
+func (c *Cmd) runMigrate() error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	s, err := c.newStore()
+	if err != nil {
+		return fmt.Errorf("failed to create store: %w", err)
+	}
+	sqlStore := s.(*store.SqlStore)
+
+	cfg := c.config.migrateConfig
+	var steps []store.MigrationStep
+	switch cfg.action {
+	case migrateStatusAction:
+		statuses, err := sqlStore.Migrations(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to get migrations: %w", err)
+		}
+		for _, st := range statuses {
+			attrs := []any{slog.Int64("version", st.Version), slog.String("name", st.Name), slog.Bool("applied", st.Applied)}
+			if st.Applied {
+				attrs = append(attrs, slog.Time("applied_at", st.AppliedAt))
+			}
+			switch {
+			case st.Unknown:
+				c.log.Warn("Migration is unknown to this version of influss", attrs...)
+			case st.Modified:
+				c.log.Warn("Migration was modified after it was applied", attrs...)
+			default:
+				c.log.Info("Migration", attrs...)
+			}
+		}
+		return nil
+	case migrateUpAction:
+		steps, err = sqlStore.Migrate(ctx, store.LatestMigration, cfg.dryRun)
+	case migrateDownAction:
+		steps, err = sqlStore.MigrateDown(ctx, cfg.dryRun)
+	case migrateToAction:
+		version, _ := strconv.ParseInt(cfg.version, 10, 64)
+		steps, err = sqlStore.Migrate(ctx, version, cfg.dryRun)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to migrate: %w", err)
+	}
+
+	if cfg.dryRun {
+		for _, step := range steps {
+			msg := "Would apply migration"
+			if step.Down {
+				msg = "Would roll back migration"
+			}
+			c.log.Info(msg, slog.Int64("version", step.Version), slog.String("name", step.Name))
+		}
+	}
+	c.log.Info("Migrated", slog.Int("migrations", len(steps)), slog.Bool("dry_run", cfg.dryRun))
+	return nil
+}
@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/timofurrer/influss/internal/retention"
+)
+
+type retentionConfig struct {
+	days     int
+	maxClips int
+	action   string
+	interval time.Duration
+	dryRun   bool
+}
+
+// retentionFlags registers the flags of the retention rules, which are shared by the serve and prune commands.
+func (c *Cmd) retentionFlags(fs *flag.FlagSet) {
+	fs.IntVar(&c.config.retentionConfig.days, "retention-days", 0, "prune clips clipped more than this number of days ago, 0 disables the rule")
+	fs.IntVar(&c.config.retentionConfig.maxClips, "retention-max-clips", 0, "prune the oldest clips beyond this number of clips, 0 disables the rule")
+	fs.StringVar(&c.config.retentionConfig.action, "retention-action", string(retention.ActionDelete), "what happens to pruned clips, one of delete or archive, which marks them as read")
+	fs.DurationVar(&c.config.retentionConfig.interval, "retention-interval", time.Hour, "the interval of applying the retention rules while serving")
+}
+
+func (c *Cmd) pruneFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&c.config.retentionConfig.dryRun, "dry-run", false, "only log the clips and files which would be pruned")
+}
+
+func (c *Cmd) validateRetentionFlags() error {
+	cfg := c.config.retentionConfig
+	if cfg.days < 0 || cfg.maxClips < 0 {
+		return errors.New("the retention rules must not be negative")
+	}
+
+	if !slices.Contains(retention.Actions, retention.Action(cfg.action)) {
+		return fmt.Errorf("invalid retention action %q, must be one of %v", cfg.action, retention.Actions)
+	}
+
+	if cfg.interval <= 0 {
+		return errors.New("the retention interval must be positive")
+	}
+
+	return nil
+}
+
+func (c *Cmd) validatePruneFlags() error {
+	if err := c.validateRetentionFlags(); err != nil {
+		return err
+	}
+	if len(c.args) != 0 {
+		return fmt.Errorf("unexpected arguments %s", strings.Join(c.args, " "))
+	}
+	return nil
+}
+
+func (c *Cmd) newRetentionConfig() retention.Config {
+	cfg := c.config.retentionConfig
+	return retention.Config{
+		MaxAge:   time.Duration(cfg.days) * 24 * time.Hour,
+		MaxClips: cfg.maxClips,
+		Action:   retention.Action(cfg.action),
+	}
+}
+
+// runPrune applies the retention rules and removes orphaned files right away.
+func (c *Cmd) runPrune() error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	s, err := c.newStore()
+	if err != nil {
+		return fmt.Errorf("failed to create store: %w", err)
+	}
+
+	res, err := retention.New(c.log, c.newRetentionConfig(), s).Prune(ctx, c.config.retentionConfig.dryRun)
+	if err != nil {
+		return fmt.Errorf("failed to prune clips: %w", err)
+	}
+	c.log.Info("Pruned clips", slog.Int("clips", len(res.Clips)), slog.Int("files", len(res.Files)), slog.Bool("dry_run", c.config.retentionConfig.dryRun))
+	return nil
+}
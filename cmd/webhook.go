@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"errors"
+	"flag"
+
+	"github.com/timofurrer/influss/internal/webhook"
+)
+
+type webhookConfig struct {
+	file          string
+	outboxFile    string
+	maxAttempts   int
+	subscriptions []webhook.Subscription
+}
+
+func (c *Cmd) webhookFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.config.webhookConfig.file, "webhooks-file", "", "the path to a YAML file with the webhook subscriptions, webhooks are disabled if not set")
+	fs.StringVar(&c.config.webhookConfig.outboxFile, "webhook-outbox-file", "webhook-outbox.json", "the path to the file keeping the pending and recent webhook deliveries")
+	fs.IntVar(&c.config.webhookConfig.maxAttempts, "webhook-max-attempts", 8, "the number of attempts after which a webhook delivery is given up")
+}
+
+func (c *Cmd) validateWebhookFlags() error {
+	cfg := &c.config.webhookConfig
+	if cfg.file == "" {
+		return nil
+	}
+	if cfg.maxAttempts < 1 {
+		return errors.New("the webhook max attempts must be at least 1")
+	}
+
+	subs, err := webhook.LoadSubscriptions(cfg.file)
+	if err != nil {
+		return err
+	}
+	cfg.subscriptions = subs
+	return nil
+}
+
+func (c *Cmd) newWebhookDispatcher() (*webhook.Dispatcher, error) {
+	cfg := c.config.webhookConfig
+	return webhook.New(c.log, webhook.Config{
+		Subscriptions: cfg.subscriptions,
+		OutboxFile:    cfg.outboxFile,
+		MaxAttempts:   cfg.maxAttempts,
+	})
+}
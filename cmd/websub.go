@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+)
+
+type websubConfig struct {
+	hubURL      string
+	embeddedHub bool
+	stateFile   string
+}
+
+func (c *Cmd) websubFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.config.websubConfig.hubURL, "websub-hub-url", "", "the URL of the WebSub hub to advertise in the feed and to ping when clips change, with --websub-embedded-hub the external URL of the embedded hub")
+	fs.BoolVar(&c.config.websubConfig.embeddedHub, "websub-embedded-hub", false, "run a WebSub hub at /websub which pushes the feed to subscribers when clips change")
+	fs.StringVar(&c.config.websubConfig.stateFile, "websub-state-file", "websub-subscriptions.json", "the path to the file keeping the subscriptions of the embedded WebSub hub")
+}
+
+func (c *Cmd) validateWebsubFlags() error {
+	cfg := &c.config.websubConfig
+	if cfg.hubURL == "" && !cfg.embeddedHub {
+		return nil
+	}
+
+	feedLink, err := url.Parse(c.config.feedLink)
+	if err != nil || !feedLink.IsAbs() {
+		return errors.New("using WebSub requires the external URL of the feed as --feed-link")
+	}
+
+	if cfg.hubURL == "" {
+		// the embedded hub is served next to the feed
+		cfg.hubURL = feedLink.ResolveReference(&url.URL{Path: "/websub"}).String()
+	}
+	if u, err := url.Parse(cfg.hubURL); err != nil || !u.IsAbs() {
+		return fmt.Errorf("invalid WebSub hub URL %q, must be an absolute URL", cfg.hubURL)
+	}
+	return nil
+}
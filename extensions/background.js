@@ -31,18 +31,26 @@ browser.runtime.onMessage.addListener((message) => {
   }
 });
 
+// How long to wait for the outcome of a clip on the event stream
+const CLIP_EVENT_TIMEOUT_MS = 120000;
+
 async function saveForLater(url) {
+  let events = null;
   try {
     // Get the endpoint and auth details from storage
     const { endpoint, username, password } = await browser.storage.sync.get(['endpoint', 'username', 'password']);
 
     if (!endpoint) {
       console.error('Endpoint not configured');
+      notify('influss is not configured', 'Configure the endpoint in the extension settings.');
       return;
     }
 
     const authHeader = 'Basic ' + btoa(`${username}:${password}`);
 
+    // Watch the event stream before clipping, so the outcome isn't missed
+    events = await watchClipEvents(new URL('events', endpoint), authHeader, url);
+
     const response = await fetch(endpoint, {
       method: 'POST',
       headers: {
@@ -52,10 +60,113 @@ async function saveForLater(url) {
       body: JSON.stringify({url: url})
     });
 
-    if (!response.ok) {
+    // Without the event stream the response is all there is to go on
+    const event = events ? await events.outcome : null;
+    if (event && event.type === 'clip.succeeded') {
+      notify('Saved for later', event.title || url);
+    } else if (event && event.type === 'clip.failed') {
+      notify('Failed to save page', event.error || url);
+    } else if (response.ok) {
+      notify('Saved for later', url);
+    } else {
       throw new Error(`HTTP error! status: ${response.status}`);
     }
   } catch (error) {
     console.error('Error saving page:', error);
+    notify('Failed to save page', error.message);
+  } finally {
+    if (events) {
+      events.close();
+    }
   }
 }
+
+// Connects to the Server-Sent Events stream of influss and resolves the outcome
+// with the clip.succeeded or clip.failed event of the URL, or with null on timeout.
+// It returns null if the stream is not available, e.g. with older influss versions.
+// EventSource can't send the Authorization header, so the stream is read with fetch.
+async function watchClipEvents(eventsURL, authHeader, url) {
+  const controller = new AbortController();
+  let response;
+  try {
+    response = await fetch(eventsURL, {
+      headers: {
+        'Accept': 'text/event-stream',
+        'Authorization': authHeader
+      },
+      signal: controller.signal
+    });
+  } catch (error) {
+    console.warn('Event stream not available:', error);
+    return null;
+  }
+  if (!response.ok || !response.body) {
+    controller.abort();
+    return null;
+  }
+
+  const outcome = new Promise((resolve) => {
+    const timeout = setTimeout(() => resolve(null), CLIP_EVENT_TIMEOUT_MS);
+    const done = (event) => {
+      clearTimeout(timeout);
+      resolve(event);
+    };
+
+    readEvents(response.body, (type, data) => {
+      if (type !== 'clip.succeeded' && type !== 'clip.failed') {
+        return;
+      }
+      const event = JSON.parse(data);
+      if (event.url === url) {
+        done(event);
+      }
+    }).then(() => done(null), () => done(null));
+  });
+
+  return {
+    outcome: outcome,
+    close: () => controller.abort()
+  };
+}
+
+// Reads the events of a Server-Sent Events stream and calls onEvent with their type and data.
+async function readEvents(body, onEvent) {
+  const reader = body.getReader();
+  const decoder = new TextDecoder();
+  let buffer = '';
+  for (;;) {
+    const { value, done } = await reader.read();
+    if (done) {
+      return;
+    }
+    buffer += decoder.decode(value, {stream: true}).replace(/\r\n?/g, '\n');
+
+    let end;
+    while ((end = buffer.indexOf('\n\n')) !== -1) {
+      const block = buffer.slice(0, end);
+      buffer = buffer.slice(end + 2);
+
+      let type = 'message';
+      const data = [];
+      for (const line of block.split('\n')) {
+        if (line.startsWith('event:')) {
+          type = line.slice(6).trim();
+        } else if (line.startsWith('data:')) {
+          data.push(line.slice(5).trimStart());
+        }
+      }
+      if (data.length > 0) {
+        onEvent(type, data.join('\n'));
+      }
+    }
+  }
+}
+
+function notify(title, message) {
+  browser.notifications.create({
+    type: 'basic',
+    iconUrl: browser.runtime.getURL('icons/influss-96.png'),
+    title: title,
+    message: message
+  });
+}
@@ -0,0 +1,67 @@
+package api
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/timofurrer/influss/internal/epub"
+	"github.com/timofurrer/influss/internal/store"
+)
+
+const (
+	defaultExportLimit = 100
+	maxExportLimit     = 500
+)
+
+// ExportEPUBFunc responds with an EPUB book of the clips matching the filters in the query, oldest first.
+// It supports the same filters as ListClipsFunc.
+func ExportEPUBFunc(log *slog.Logger, s store.Store, cfg epub.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := r.URL.Query()
+
+		q, err := parseClipsQuery(params)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Invalid query: %s", err), http.StatusBadRequest)
+			return
+		}
+		limit, err := positiveIntParam(params, "limit", defaultExportLimit)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Invalid query: %s", err), http.StatusBadRequest)
+			return
+		}
+		q.Limit = min(limit, maxExportLimit)
+		q.Ascending = params.Get("order") != "desc"
+
+		clips, _, err := s.List(r.Context(), q)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Error listing clips: %s", err), http.StatusInternalServerError)
+			return
+		}
+		if len(clips) == 0 {
+			http.Error(w, "No clips match the filters", http.StatusNotFound)
+			return
+		}
+
+		cfg := cfg
+		cfg.Title = cmp.Or(params.Get("title"), cfg.Title)
+		b := epub.NewBuilder(log, cfg)
+		for _, c := range clips {
+			b.WithClip(c)
+		}
+
+		var buf bytes.Buffer
+		if err := b.Write(r.Context(), &buf); err != nil {
+			http.Error(w, fmt.Sprintf("Error building EPUB: %s", err), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/epub+zip")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="influss-%s.epub"`, time.Now().Format(time.DateOnly)))
+		w.WriteHeader(http.StatusOK)
+		w.Write(buf.Bytes())
+	}
+}
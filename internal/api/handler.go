@@ -1,14 +1,21 @@
 package api
 
 import (
+	"cmp"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 
 	"github.com/timofurrer/influss/internal/clip"
 	"github.com/timofurrer/influss/internal/feed"
+	"github.com/timofurrer/influss/internal/feedcache"
 	"github.com/timofurrer/influss/internal/store"
 )
 
@@ -16,7 +23,7 @@ type clipRequest struct {
 	URL string `json:"url"`
 }
 
-func ClipURLFunc(log *slog.Logger, store store.Store) http.HandlerFunc {
+func ClipURLFunc(log *slog.Logger, clipper *clip.Clipper, store store.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		body, err := io.ReadAll(r.Body)
 		if err != nil {
@@ -34,38 +41,204 @@ func ClipURLFunc(log *slog.Logger, store store.Store) http.HandlerFunc {
 
 		log.Info("Received request to clip URL", slog.String("url", req.URL))
 
-		clip, err := clip.ClipURL(req.URL)
-		if err != nil {
+		if _, err := ClipAndStore(r.Context(), clipper, store, req.URL, ""); err != nil {
 			http.Error(w, fmt.Sprintf("Error clipping URL: %s", err), http.StatusInternalServerError)
 			return
 		}
 
-		err = store.Store(r.Context(), clip)
+		w.WriteHeader(http.StatusCreated)
+	}
+}
+
+// ClipAndStore clips the given URL and stores it, the given title is used if the page has none.
+// The tags, read and starred state of a previously stored clip of the URL are kept.
+func ClipAndStore(ctx context.Context, clipper *clip.Clipper, s store.Store, url, title string) (*clip.Clip, error) {
+	c, err := clipper.ClipURL(url)
+	if err != nil {
+		return nil, err
+	}
+	c.Title = cmp.Or(c.Title, title, c.URL)
+
+	existing, err := s.Get(ctx, c.URL)
+	switch {
+	case err == nil:
+		c.Tags = existing.Tags
+		c.Read = existing.Read
+		c.Starred = existing.Starred
+	case !errors.Is(err, store.ErrNotFound):
+		return nil, fmt.Errorf("failed to load existing clip: %w", err)
+	}
+
+	if err := s.Store(ctx, c); err != nil {
+		return nil, fmt.Errorf("failed to store clip: %w", err)
+	}
+	return c, nil
+}
+
+// GetFeedFunc serves the feed, the cache is optional and may be nil.
+func GetFeedFunc(config feed.Config, itemsLimit int, store store.Store, cache *feedcache.Cache) http.HandlerFunc {
+	return feedFunc(config, itemsLimit, store, cache, false)
+}
+
+// GetStarredFeedFunc serves the feed of the starred clips, the cache is optional and may be nil.
+func GetStarredFeedFunc(config feed.Config, itemsLimit int, store store.Store, cache *feedcache.Cache) http.HandlerFunc {
+	return feedFunc(config, itemsLimit, store, cache, true)
+}
+
+func feedFunc(config feed.Config, itemsLimit int, store store.Store, cache *feedcache.Cache, starred bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		variant, err := parseFeedVariant(r.URL.Query())
 		if err != nil {
-			http.Error(w, fmt.Sprintf("Error storing clipped URL: %s", err), http.StatusInternalServerError)
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
+		variant.starred = starred
 
-		w.WriteHeader(http.StatusCreated)
+		if cache == nil {
+			data, err := renderFeed(r.Context(), config, itemsLimit, store, variant)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+			w.Write(data)
+			return
+		}
+
+		entry, generation, ok := cache.Get(variant.key())
+		if !ok {
+			data, err := renderFeed(r.Context(), config, itemsLimit, store, variant)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			entry = cache.Put(variant.key(), generation, data)
+		}
+		entry.Serve(w, r, "text/xml; charset=utf-8")
 	}
 }
 
-func GetFeedFunc(config feed.Config, itemsLimit int, store store.Store) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		clips := store.Load(r.Context(), itemsLimit)
+// FeedParamError is returned for invalid query parameters of the feed URL.
+type FeedParamError struct {
+	Param string
+	Value string
+}
+
+func (e *FeedParamError) Error() string {
+	return fmt.Sprintf("Invalid %s query parameter: %q", e.Param, e.Value)
+}
+
+// feedVariant is the variant of the feed selected by the query parameters of the feed URL.
+type feedVariant struct {
+	maxMinutes int
+	// starred selects the feed of the starred clips, which is served below the feed URL at /starred.
+	starred bool
+}
+
+func parseFeedVariant(params url.Values) (feedVariant, error) {
+	var v feedVariant
+	if s := params.Get("max_minutes"); s != "" {
+		var err error
+		v.maxMinutes, err = strconv.Atoi(s)
+		if err != nil || v.maxMinutes <= 0 {
+			return v, &FeedParamError{Param: "max_minutes", Value: s}
+		}
+	}
+	return v, nil
+}
+
+// query returns the canonical query parameters of the variant.
+func (v feedVariant) query() url.Values {
+	q := url.Values{}
+	if v.maxMinutes > 0 {
+		q.Set("max_minutes", strconv.Itoa(v.maxMinutes))
+	}
+	return q
+}
 
-		fb := feed.NewBuidler(config)
-		for _, c := range clips {
-			fb.WithClip(c)
+// key identifies the variant in the feed cache.
+func (v feedVariant) key() string {
+	if v.starred {
+		return "rss/starred?" + v.query().Encode()
+	}
+	return "rss?" + v.query().Encode()
+}
+
+// RenderFeed renders the feed of the clips, filtered by the given query parameters of the feed URL.
+// It returns a FeedParamError if the parameters are invalid.
+func RenderFeed(ctx context.Context, config feed.Config, itemsLimit int, store store.Store, params url.Values) ([]byte, error) {
+	variant, err := parseFeedVariant(params)
+	if err != nil {
+		return nil, err
+	}
+	return renderFeed(ctx, config, itemsLimit, store, variant)
+}
+
+// renderFeed renders the variant of the feed.
+// The self link of the feed is the feed link of the config with the path and query parameters of the variant.
+func renderFeed(ctx context.Context, config feed.Config, itemsLimit int, s store.Store, variant feedVariant) ([]byte, error) {
+	if variant.starred {
+		config.Title += " (starred)"
+		// the hub only publishes the feed itself
+		config.HubURL = ""
+	}
+	if config.SelfURL == "" && config.Link != "" {
+		if self, err := url.Parse(config.Link); err == nil {
+			if variant.starred {
+				self.Path = strings.TrimSuffix(self.Path, "/") + "/starred"
+			}
+			self.RawQuery = variant.query().Encode()
+			config.SelfURL = self.String()
 		}
+	}
 
-		data, err := fb.ToXML()
+	q := store.Query{MaxReadingMinutes: variant.maxMinutes, Limit: itemsLimit}
+	if variant.starred {
+		starred := true
+		q.Starred = &starred
+	}
+	clips, _, err := s.List(ctx, q)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list clips: %w", err)
+	}
+
+	fb := feed.NewBuidler(config)
+	for _, c := range clips {
+		fb.WithClip(c)
+	}
+
+	return fb.ToXML()
+}
+
+func GetClipMarkdownFunc(s store.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		url := r.URL.Query().Get("url")
+		if url == "" {
+			http.Error(w, "Missing url query parameter", http.StatusBadRequest)
+			return
+		}
+
+		c, err := s.Get(r.Context(), url)
+		if errors.Is(err, store.ErrNotFound) {
+			http.Error(w, fmt.Sprintf("No clip for URL %s", url), http.StatusNotFound)
+			return
+		}
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			http.Error(w, fmt.Sprintf("Error loading clip: %s", err), http.StatusInternalServerError)
 			return
 		}
 
+		md := c.MarkdownContent
+		if md == "" && c.HTMLContent != "" {
+			md, err = clip.HTMLToMarkdown(c.HTMLContent)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("Error converting clip to markdown: %s", err), http.StatusInternalServerError)
+				return
+			}
+		}
+
+		w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
 		w.WriteHeader(http.StatusOK)
-		w.Write([]byte(data))
+		w.Write([]byte(fmt.Sprintf("# %s\n\n<%s>\n\n%s", c.Title, c.URL, md)))
 	}
 }
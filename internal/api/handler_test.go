@@ -0,0 +1,50 @@
+package api_test
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/timofurrer/influss/internal/api"
+	"github.com/timofurrer/influss/internal/clip"
+	"github.com/timofurrer/influss/internal/feed"
+	"github.com/timofurrer/influss/internal/store"
+)
+
+func TestRenderFeedFiltersByReadingTime(t *testing.T) {
+	ctx := context.Background()
+	s := store.NewMemoryStore()
+	base := time.Now().Add(-time.Hour)
+	// the short clips are older than the most recent long clips
+	for i := range 10 {
+		minutes := 5
+		if i >= 5 {
+			minutes = 30
+		}
+		c := &clip.Clip{
+			URL:                fmt.Sprintf("https://example.com/%d", i),
+			Title:              fmt.Sprintf("Clip %d", i),
+			ClippedAt:          base.Add(time.Duration(i) * time.Minute),
+			ReadingTimeMinutes: minutes,
+		}
+		if err := s.Store(ctx, c); err != nil {
+			t.Fatalf("failed to store clip: %v", err)
+		}
+	}
+
+	data, err := api.RenderFeed(ctx, feed.Config{Title: "influss"}, 3, s, url.Values{"max_minutes": {"10"}})
+	if err != nil {
+		t.Fatalf("failed to render feed: %v", err)
+	}
+	if got := strings.Count(string(data), "<item>"); got != 3 {
+		t.Errorf("expected 3 items, got %d:\n%s", got, data)
+	}
+	for _, want := range []string{"https://example.com/4", "https://example.com/3", "https://example.com/2"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("expected item %s in feed:\n%s", want, data)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package api
+
+import (
+	"cmp"
+	"crypto/subtle"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/timofurrer/influss/internal/clip"
+	"github.com/timofurrer/influss/internal/store"
+)
+
+//go:embed templates/*.html
+var templatesFS embed.FS
+
+var templates = template.Must(template.ParseFS(templatesFS, "templates/*.html"))
+
+type savePage struct {
+	Clip  *clip.Clip
+	Error string
+	Close bool
+}
+
+// SaveURLFunc clips the URL given in the query and responds with a confirmation page.
+// The page closes itself after a successful save if the close query parameter is set,
+// which is used by the bookmarklet.
+// The save token must be given as query parameter, because the request is a plain GET from a foreign page.
+func SaveURLFunc(log *slog.Logger, clipper *clip.Clipper, store store.Store, token string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		setPrivatePageHeaders(w)
+
+		q := r.URL.Query()
+		if !validSaveToken(q.Get("token"), token) {
+			renderTemplate(w, http.StatusForbidden, "save.html", savePage{Error: "Invalid or missing save token"})
+			return
+		}
+		if q.Get("url") == "" {
+			renderTemplate(w, http.StatusBadRequest, "save.html", savePage{Error: "Missing url query parameter"})
+			return
+		}
+
+		log.Info("Received request to save URL", slog.String("url", q.Get("url")))
+
+		c, err := ClipAndStore(r.Context(), clipper, store, q.Get("url"), q.Get("title"))
+		if err != nil {
+			renderTemplate(w, http.StatusInternalServerError, "save.html", savePage{Error: fmt.Sprintf("Error clipping URL: %s", err)})
+			return
+		}
+		renderTemplate(w, http.StatusCreated, "save.html", savePage{Clip: c, Close: q.Get("close") != ""})
+	}
+}
+
+type saveResponse struct {
+	URL                string `json:"url,omitempty"`
+	Title              string `json:"title,omitempty"`
+	ReadingTimeMinutes int    `json:"reading_time_minutes,omitempty"`
+	Error              string `json:"error,omitempty"`
+}
+
+// SaveURLJSONFunc is the variant of SaveURLFunc for automation tools like iOS Shortcuts.
+// The url, title and token are taken from the query, a form or JSON body
+// and the token may also be given as bearer token. It responds with JSON.
+func SaveURLJSONFunc(log *slog.Logger, clipper *clip.Clipper, store store.Store, token string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params, err := saveParams(r)
+		if err != nil {
+			writeAPIResponse(w, http.StatusBadRequest, saveResponse{Error: err.Error()})
+			return
+		}
+
+		given := params.Get("token")
+		if bearer, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); found {
+			given = bearer
+		}
+		if !validSaveToken(given, token) {
+			writeAPIResponse(w, http.StatusForbidden, saveResponse{Error: "invalid or missing save token"})
+			return
+		}
+		if params.Get("url") == "" {
+			writeAPIResponse(w, http.StatusBadRequest, saveResponse{Error: "missing url parameter"})
+			return
+		}
+
+		log.Info("Received request to save URL", slog.String("url", params.Get("url")))
+
+		c, err := ClipAndStore(r.Context(), clipper, store, params.Get("url"), params.Get("title"))
+		if err != nil {
+			writeAPIResponse(w, http.StatusInternalServerError, saveResponse{Error: fmt.Sprintf("error clipping URL: %s", err)})
+			return
+		}
+		writeAPIResponse(w, http.StatusCreated, saveResponse{URL: c.URL, Title: c.Title, ReadingTimeMinutes: c.ReadingTimeMinutes})
+	}
+}
+
+type bookmarkletPage struct {
+	Bookmarklet template.URL
+	ShortcutURL string
+}
+
+// BookmarkletFunc renders a page with a bookmarklet for the save endpoint.
+// The bookmarklet embeds the save token, therefore the page asks for it first.
+func BookmarkletFunc(token string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		setPrivatePageHeaders(w)
+
+		given := r.URL.Query().Get("token")
+		if !validSaveToken(given, token) {
+			status := http.StatusOK
+			if given != "" {
+				status = http.StatusForbidden
+			}
+			renderTemplate(w, status, "bookmarklet.html", bookmarkletPage{})
+			return
+		}
+
+		base := requestBaseURL(r)
+		saveURL := base + "/save?close=1&token=" + url.QueryEscape(given)
+		renderTemplate(w, http.StatusOK, "bookmarklet.html", bookmarkletPage{
+			Bookmarklet: bookmarklet(saveURL),
+			ShortcutURL: base + "/save.json?token=" + url.QueryEscape(given) + "&url=",
+		})
+	}
+}
+
+// bookmarklet returns the javascript: URL which opens the save URL for the current page in a popup.
+func bookmarklet(saveURL string) template.URL {
+	quoted, _ := json.Marshal(saveURL)
+	code := fmt.Sprintf(
+		"javascript:(function(){window.open(%s+'&url='+encodeURIComponent(location.href)+'&title='+encodeURIComponent(document.title),'influss','width=420,height=260');})();",
+		quoted,
+	)
+	// browsers percent-decode javascript: URLs before running them
+	return template.URL(strings.ReplaceAll(code, "%", "%25"))
+}
+
+func saveParams(r *http.Request) (url.Values, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "application/json" {
+		if err := r.ParseForm(); err != nil {
+			return nil, fmt.Errorf("error parsing request: %w", err)
+		}
+		return r.Form, nil
+	}
+
+	var body struct {
+		URL   string `json:"url"`
+		Title string `json:"title"`
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("error parsing request body: %w", err)
+	}
+	params := r.URL.Query()
+	for k, v := range map[string]string{"url": body.URL, "title": body.Title, "token": body.Token} {
+		if v != "" {
+			params.Set(k, v)
+		}
+	}
+	return params, nil
+}
+
+func validSaveToken(given, token string) bool {
+	return token != "" && subtle.ConstantTimeCompare([]byte(given), []byte(token)) == 1
+}
+
+// setPrivatePageHeaders prevents pages with the save token in their URL
+// from being cached, framed or leaking the token via the referrer.
+func setPrivatePageHeaders(w http.ResponseWriter) {
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	w.Header().Set("X-Frame-Options", "DENY")
+}
+
+// requestBaseURL returns the URL the server was reached at, respecting reverse proxy headers.
+func requestBaseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	scheme = cmp.Or(r.Header.Get("X-Forwarded-Proto"), scheme)
+	host := cmp.Or(r.Header.Get("X-Forwarded-Host"), r.Host)
+	return scheme + "://" + host
+}
+
+func renderTemplate(w http.ResponseWriter, status int, name string, data any) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	templates.ExecuteTemplate(w, name, data)
+}
@@ -0,0 +1,345 @@
+package api
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/timofurrer/influss/internal/clip"
+	"github.com/timofurrer/influss/internal/store"
+)
+
+//go:embed openapi.json
+var openAPISpec []byte
+
+const (
+	defaultPerPage = 50
+	maxPerPage     = 500
+)
+
+// clipFields are the fields of a clip in API responses.
+var clipFields = []string{
+	"id", "url", "domain", "title", "author", "excerpt",
+	"site_name", "image", "favicon", "language",
+	"word_count", "reading_time_minutes",
+	"published_at", "modified_at", "clipped_at",
+	"tags", "read", "starred",
+	"html_content", "markdown_content", "plain_text_content",
+}
+
+// contentFields are only returned if explicitly selected.
+var contentFields = []string{"html_content", "markdown_content", "plain_text_content"}
+
+type apiError struct {
+	Error apiErrorDetails `json:"error"`
+}
+
+type apiErrorDetails struct {
+	Status  int    `json:"status"`
+	Message string `json:"message"`
+}
+
+type clipsResponse struct {
+	Clips   []map[string]any `json:"clips"`
+	Page    int              `json:"page"`
+	PerPage int              `json:"per_page"`
+	Total   int              `json:"total"`
+	Prev    string           `json:"prev,omitempty"`
+	Next    string           `json:"next,omitempty"`
+}
+
+// ListClipsFunc lists the clips matching the filters in the query.
+func ListClipsFunc(s store.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := r.URL.Query()
+
+		q, err := parseClipsQuery(params)
+		if err != nil {
+			writeAPIError(w, http.StatusBadRequest, err)
+			return
+		}
+		fields, err := parseFields(params.Get("fields"))
+		if err != nil {
+			writeAPIError(w, http.StatusBadRequest, err)
+			return
+		}
+		page, err := positiveIntParam(params, "page", 1)
+		if err != nil {
+			writeAPIError(w, http.StatusBadRequest, err)
+			return
+		}
+		perPage, err := positiveIntParam(params, "per_page", defaultPerPage)
+		if err != nil {
+			writeAPIError(w, http.StatusBadRequest, err)
+			return
+		}
+		perPage = min(perPage, maxPerPage)
+		q.Offset = (page - 1) * perPage
+		q.Limit = perPage
+
+		clips, total, err := s.List(r.Context(), q)
+		if err != nil {
+			writeAPIError(w, http.StatusInternalServerError, fmt.Errorf("failed to list clips: %w", err))
+			return
+		}
+
+		resp := clipsResponse{
+			Clips:   make([]map[string]any, 0, len(clips)),
+			Page:    page,
+			PerPage: perPage,
+			Total:   total,
+		}
+		for _, c := range clips {
+			// listed clips don't include the plain text and markdown content
+			if slices.Contains(fields, "markdown_content") || slices.Contains(fields, "plain_text_content") {
+				if c, err = s.GetByID(r.Context(), c.ID); err != nil {
+					writeAPIError(w, http.StatusInternalServerError, fmt.Errorf("failed to load clip: %w", err))
+					return
+				}
+			}
+			resp.Clips = append(resp.Clips, clipJSON(c, fields))
+		}
+
+		pageURL := func(p int) string {
+			params.Set("page", strconv.Itoa(p))
+			return r.URL.Path + "?" + params.Encode()
+		}
+		if page > 1 {
+			resp.Prev = pageURL(page - 1)
+		}
+		if page*perPage < total {
+			resp.Next = pageURL(page + 1)
+		}
+
+		writeAPIResponse(w, http.StatusOK, resp)
+	}
+}
+
+// GetClipFunc returns the clip with the id in the path.
+func GetClipFunc(s store.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			writeAPIError(w, http.StatusBadRequest, fmt.Errorf("invalid clip id %q", r.PathValue("id")))
+			return
+		}
+		fields, err := parseFields(r.URL.Query().Get("fields"))
+		if err != nil {
+			writeAPIError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		c, err := s.GetByID(r.Context(), id)
+		if errors.Is(err, store.ErrNotFound) {
+			writeAPIError(w, http.StatusNotFound, fmt.Errorf("no clip with id %d", id))
+			return
+		}
+		if err != nil {
+			writeAPIError(w, http.StatusInternalServerError, fmt.Errorf("failed to load clip: %w", err))
+			return
+		}
+
+		writeAPIResponse(w, http.StatusOK, clipJSON(c, fields))
+	}
+}
+
+// StarClipFunc stars or unstars the clip with the id in the path and returns it.
+func StarClipFunc(s store.Store, starred bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			writeAPIError(w, http.StatusBadRequest, fmt.Errorf("invalid clip id %q", r.PathValue("id")))
+			return
+		}
+
+		c, err := s.GetByID(r.Context(), id)
+		if errors.Is(err, store.ErrNotFound) {
+			writeAPIError(w, http.StatusNotFound, fmt.Errorf("no clip with id %d", id))
+			return
+		}
+		if err != nil {
+			writeAPIError(w, http.StatusInternalServerError, fmt.Errorf("failed to load clip: %w", err))
+			return
+		}
+
+		if c.Starred != starred {
+			c.Starred = starred
+			if err := s.Store(r.Context(), c); err != nil {
+				writeAPIError(w, http.StatusInternalServerError, fmt.Errorf("failed to store clip: %w", err))
+				return
+			}
+		}
+
+		fields, _ := parseFields("")
+		writeAPIResponse(w, http.StatusOK, clipJSON(c, fields))
+	}
+}
+
+// OpenAPIFunc serves the OpenAPI specification of the JSON API.
+func OpenAPIFunc() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(openAPISpec)
+	}
+}
+
+// NotFoundAPIFunc responds to unknown API paths with a JSON error.
+func NotFoundAPIFunc() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeAPIError(w, http.StatusNotFound, fmt.Errorf("no API endpoint %s %s", r.Method, r.URL.Path))
+	}
+}
+
+func parseClipsQuery(params url.Values) (store.Query, error) {
+	q := store.Query{
+		Tags:      params["tag"],
+		Domain:    params.Get("domain"),
+		Search:    params.Get("q"),
+		Ascending: params.Get("order") == "asc",
+	}
+
+	switch order := params.Get("order"); order {
+	case "", "asc", "desc":
+	default:
+		return q, fmt.Errorf("invalid order %q, must be asc or desc", order)
+	}
+
+	// the unread queue leaves out the starred clips
+	switch state := params.Get("state"); state {
+	case "", "all":
+	case "unread":
+		q.Read = new(bool)
+		q.Starred = new(bool)
+	case "read":
+		read := true
+		q.Read = &read
+	case "starred":
+		starred := true
+		q.Starred = &starred
+	default:
+		return q, fmt.Errorf("invalid state %q, must be one of all, read, unread or starred", state)
+	}
+
+	var err error
+	if q.Since, err = timeParam(params, "since"); err != nil {
+		return q, err
+	}
+	if q.Until, err = timeParam(params, "until"); err != nil {
+		return q, err
+	}
+	return q, nil
+}
+
+// parseFields parses a comma-separated list of fields.
+// Without fields all fields except the content ones are selected.
+func parseFields(s string) ([]string, error) {
+	if s == "" {
+		return slices.DeleteFunc(slices.Clone(clipFields), func(f string) bool {
+			return slices.Contains(contentFields, f)
+		}), nil
+	}
+
+	var fields []string
+	for _, f := range strings.Split(s, ",") {
+		f = strings.TrimSpace(f)
+		if !slices.Contains(clipFields, f) {
+			return nil, fmt.Errorf("unknown field %q, must be one of %s", f, strings.Join(clipFields, ", "))
+		}
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+// timeParam parses a time given as RFC 3339 timestamp or date.
+func timeParam(params url.Values, key string) (time.Time, error) {
+	v := params.Get(key)
+	if v == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.DateOnly, v); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid %s %q, must be a RFC 3339 timestamp or a date like 2006-01-02", key, v)
+}
+
+func positiveIntParam(params url.Values, key string, fallback int) (int, error) {
+	v := params.Get(key)
+	if v == "" {
+		return fallback, nil
+	}
+	i, err := strconv.Atoi(v)
+	if err != nil || i < 1 {
+		return 0, fmt.Errorf("invalid %s %q, must be a positive number", key, v)
+	}
+	return i, nil
+}
+
+// clipJSON returns the given fields of the clip.
+func clipJSON(c *clip.Clip, fields []string) map[string]any {
+	tags := c.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	var domain string
+	if u, err := url.Parse(c.URL); err == nil {
+		domain = u.Hostname()
+	}
+
+	all := map[string]any{
+		"id":                   c.ID,
+		"url":                  c.URL,
+		"domain":               domain,
+		"title":                c.Title,
+		"author":               c.Author,
+		"excerpt":              c.Excerpt,
+		"site_name":            c.SiteName,
+		"image":                c.Image,
+		"favicon":              c.Favicon,
+		"language":             c.Language,
+		"word_count":           c.WordCount,
+		"reading_time_minutes": c.ReadingTimeMinutes,
+		"published_at":         optionalTime(c.PublishedAt),
+		"modified_at":          optionalTime(c.ModifiedAt),
+		"clipped_at":           optionalTime(c.ClippedAt),
+		"tags":                 tags,
+		"read":                 c.Read,
+		"starred":              c.Starred,
+		"html_content":         c.HTMLContent,
+		"markdown_content":     c.MarkdownContent,
+		"plain_text_content":   c.PlainTextContent,
+	}
+
+	selected := make(map[string]any, len(fields))
+	for _, f := range fields {
+		selected[f] = all[f]
+	}
+	return selected
+}
+
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+func writeAPIResponse(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeAPIError(w http.ResponseWriter, status int, err error) {
+	writeAPIResponse(w, status, apiError{Error: apiErrorDetails{Status: status, Message: err.Error()}})
+}
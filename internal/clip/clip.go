@@ -3,12 +3,20 @@ package clip
 import (
 	"cmp"
 	"fmt"
+	"log/slog"
+	"net/http"
+	nurl "net/url"
+	"strings"
 	"time"
 
+	"github.com/go-shiori/dom"
 	"github.com/go-shiori/go-readability"
+	"golang.org/x/net/html"
 )
 
 type Clip struct {
+	// ID is assigned by the store.
+	ID               int64
 	URL              string
 	Title            string
 	Author           string
@@ -17,17 +25,136 @@ type Clip struct {
 	Excerpt          string
 	HTMLContent      string
 	PlainTextContent string
+	MarkdownContent  string
+
+	WordCount          int
+	ReadingTimeMinutes int
+	Language           string
+
+	Image    string
+	SiteName string
+	Favicon  string
+
+	// ClippedAt is when the clip was saved, it's set by the store if it's zero.
+	ClippedAt time.Time
+	Tags      []string
+	Read      bool
+	// Starred clips are kept as reference material, they are never pruned and not part of the unread queue.
+	Starred bool
+}
+
+type Config struct {
+	// RulesDir is an optional directory with site-specific rules which add to or override the built-in rules.
+	RulesDir string
+	// Timeout is the timeout for fetching a single page.
+	Timeout time.Duration
+	// MaxPages is the maximum number of pages of a multi-page article which are stitched together.
+	// A value of 1 or less only clips the first page.
+	MaxPages int
+	// OnQueued is called before a URL is clipped, e.g. to report the progress.
+	OnQueued func(url string)
+	// OnFailure is called when clipping a URL fails, e.g. to notify about it.
+	OnFailure func(url string, err error)
+}
+
+type Clipper struct {
+	log       *slog.Logger
+	rules     *Rules
+	client    *http.Client
+	maxPages  int
+	onQueued  func(url string)
+	onFailure func(url string, err error)
+}
+
+func NewClipper(log *slog.Logger, cfg Config) (*Clipper, error) {
+	rules, err := LoadRules(cfg.RulesDir)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Clipper{
+		log:       log,
+		rules:     rules,
+		client:    &http.Client{Timeout: cmp.Or(cfg.Timeout, 30*time.Second)},
+		maxPages:  cfg.MaxPages,
+		onQueued:  cfg.OnQueued,
+		onFailure: cfg.OnFailure,
+	}, nil
+}
+
+func (c *Clipper) ClipURL(url string) (*Clip, error) {
+	if c.onQueued != nil {
+		c.onQueued(url)
+	}
+	clip, err := c.clipURL(url)
+	if err != nil && c.onFailure != nil {
+		c.onFailure(url, err)
+	}
+	return clip, err
+}
+
+func (c *Clipper) clipURL(url string) (*Clip, error) {
+	pageURL, err := nurl.ParseRequestURI(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse URL: %w", err)
+	}
+
+	rule := c.rules.ForHost(pageURL.Hostname())
+	if rule != nil {
+		if pageURL, err = nurl.ParseRequestURI(rule.rewriteURL(pageURL.String())); err != nil {
+			return nil, fmt.Errorf("failed to parse rewritten URL: %w", err)
+		}
+	}
+
+	doc, pageURL, err := c.fetch(pageURL)
+	if err != nil {
+		return nil, err
+	}
+
+	if rule != nil {
+		if link := rule.singlePageURL(doc); link != "" {
+			if singlePageURL, err := pageURL.Parse(link); err == nil {
+				if doc, pageURL, err = c.fetch(singlePageURL); err != nil {
+					return nil, fmt.Errorf("failed to get single page view: %w", err)
+				}
+			}
+		}
+	}
+
+	clip, err := c.extract(doc, pageURL, rule)
+	if err != nil {
+		return nil, err
+	}
+	clip.URL = url
+
+	if c.maxPages > 1 {
+		c.stitchPages(c.log.With(slog.String("url", url)), clip, doc, pageURL, rule)
+	}
+
+	clip.WithMetadata()
+
+	clip.MarkdownContent, err = HTMLToMarkdown(clip.HTMLContent)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert article to markdown: %w", err)
+	}
+
+	return clip, nil
 }
 
-func ClipURL(url string) (*Clip, error) {
-	article, err := readability.FromURL(url, 30*time.Second)
+// extract extracts the article from the given document using readability and the site-specific rule, if any.
+func (c *Clipper) extract(doc *html.Node, pageURL *nurl.URL, rule *Rule) (*Clip, error) {
+	if rule != nil {
+		rule.stripElements(doc)
+	}
+
+	article, err := readability.FromDocument(doc, pageURL)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get article: %w", err)
 	}
 
 	now := time.Now()
 	clip := &Clip{
-		URL:              url,
+		URL:              pageURL.String(),
 		Title:            article.Title,
 		Author:           article.Byline,
 		PublishedAt:      *cmp.Or(article.PublishedTime, &now),
@@ -35,7 +162,83 @@ func ClipURL(url string) (*Clip, error) {
 		Excerpt:          article.Excerpt,
 		HTMLContent:      article.Content,
 		PlainTextContent: article.TextContent,
+		Language:         normalizeLanguage(article.Language),
+		Image:            resolveURL(pageURL, article.Image),
+		SiteName:         cmp.Or(article.SiteName, pageURL.Hostname()),
+		Favicon:          article.Favicon,
+	}
+
+	if rule != nil {
+		absolutizeURLs(doc, pageURL)
+		rule.extract(doc, clip)
 	}
 
 	return clip, nil
 }
+
+// FromContent creates a clip from already extracted article HTML content,
+// e.g. from the export of another read-it-later service.
+// The content doesn't go through readability, therefore it's sanitized.
+func FromContent(url, title, content string) (*Clip, error) {
+	pageURL, err := nurl.Parse(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse URL: %w", err)
+	}
+
+	doc, err := dom.FastParse(strings.NewReader(content))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse content: %w", err)
+	}
+	absolutizeURLs(doc, pageURL)
+
+	body := dom.QuerySelector(doc, "body")
+	if body == nil {
+		body = doc
+	}
+	sanitize(body)
+
+	now := time.Now()
+	clip := &Clip{
+		URL:              url,
+		Title:            title,
+		PublishedAt:      now,
+		ModifiedAt:       now,
+		HTMLContent:      dom.InnerHTML(body),
+		PlainTextContent: strings.TrimSpace(dom.TextContent(body)),
+		SiteName:         pageURL.Hostname(),
+	}
+	clip.Excerpt = excerpt(clip.PlainTextContent)
+	clip.WithMetadata()
+
+	clip.MarkdownContent, err = HTMLToMarkdown(clip.HTMLContent)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert content to markdown: %w", err)
+	}
+
+	return clip, nil
+}
+
+// fetch gets the HTML document at the given URL.
+// It returns the parsed document and the final URL after following redirects.
+func (c *Clipper) fetch(pageURL *nurl.URL) (*html.Node, *nurl.URL, error) {
+	resp, err := c.client.Get(pageURL.String())
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch the page: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("failed to fetch the page: unexpected status %s", resp.Status)
+	}
+
+	if !strings.Contains(resp.Header.Get("Content-Type"), "text/html") {
+		return nil, nil, fmt.Errorf("URL is not a HTML document")
+	}
+
+	doc, err := dom.Parse(resp.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse the page: %w", err)
+	}
+
+	return doc, resp.Request.URL, nil
+}
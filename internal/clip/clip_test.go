@@ -0,0 +1,34 @@
+package clip_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/timofurrer/influss/internal/clip"
+)
+
+func TestFromContentSanitizesContent(t *testing.T) {
+	content := `<html><body>
+<p onclick="steal()">Text <a href="javascript:steal()">link</a> <a href="/relative">relative</a></p>
+<script>steal()</script><iframe src="https://example.com/frame"></iframe>
+<img src="image.png" onerror="steal()" style="width: 100%">
+</body></html>`
+
+	c, err := clip.FromContent("https://example.com/article", "Article", content)
+	if err != nil {
+		t.Fatalf("failed to create clip: %v", err)
+	}
+	for _, unsafe := range []string{"steal", "iframe", "style"} {
+		if strings.Contains(c.HTMLContent, unsafe) {
+			t.Errorf("expected content without %q, got %q", unsafe, c.HTMLContent)
+		}
+		if strings.Contains(c.PlainTextContent, unsafe) || strings.Contains(c.MarkdownContent, unsafe) {
+			t.Errorf("expected text and markdown without %q, got %q and %q", unsafe, c.PlainTextContent, c.MarkdownContent)
+		}
+	}
+	for _, kept := range []string{`<p>Text <a>link</a>`, `href="https://example.com/relative"`, `<img src="https://example.com/image.png"/>`} {
+		if !strings.Contains(c.HTMLContent, kept) {
+			t.Errorf("expected content with %q, got %q", kept, c.HTMLContent)
+		}
+	}
+}
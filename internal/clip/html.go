@@ -0,0 +1,75 @@
+package clip
+
+import (
+	nurl "net/url"
+	"strings"
+
+	"github.com/go-shiori/dom"
+	"golang.org/x/net/html"
+)
+
+// urlAttributes are the attributes containing URLs which are made absolute.
+var urlAttributes = []string{"href", "src", "poster"}
+
+// absolutizeURLs resolves all relative URLs in the attributes of the elements in doc
+// against the given base URL.
+func absolutizeURLs(doc *html.Node, base *nurl.URL) {
+	for _, n := range dom.GetElementsByTagName(doc, "*") {
+		for _, attr := range urlAttributes {
+			v := strings.TrimSpace(dom.GetAttribute(n, attr))
+			if v == "" || strings.HasPrefix(v, "#") || strings.HasPrefix(v, "data:") {
+				continue
+			}
+			if u, err := base.Parse(v); err == nil {
+				dom.SetAttribute(n, attr, u.String())
+			}
+		}
+	}
+}
+
+// resolveURL resolves the possibly relative URL u against base.
+// It returns an empty string if u is empty or invalid.
+func resolveURL(base *nurl.URL, u string) string {
+	u = strings.TrimSpace(u)
+	if u == "" {
+		return ""
+	}
+	resolved, err := base.Parse(u)
+	if err != nil {
+		return ""
+	}
+	return resolved.String()
+}
+
+// unsafeElements are removed from content which didn't go through readability,
+// because they run scripts, load other pages, submit data or restyle the page showing the content.
+var unsafeElements = []string{
+	"script", "style", "link", "meta", "base",
+	"iframe", "frame", "frameset", "object", "embed", "applet",
+	"form", "input", "button", "select", "textarea",
+}
+
+// sanitize removes the unsafe elements, event handler and style attributes
+// and script URLs from n and its descendants.
+func sanitize(n *html.Node) {
+	for _, tag := range unsafeElements {
+		dom.RemoveNodes(dom.GetElementsByTagName(n, tag), nil)
+	}
+	for _, el := range append([]*html.Node{n}, dom.GetElementsByTagName(n, "*")...) {
+		attrs := el.Attr[:0]
+		for _, attr := range el.Attr {
+			key := strings.ToLower(attr.Key)
+			if strings.HasPrefix(key, "on") || key == "style" || isScriptURL(attr.Val) {
+				continue
+			}
+			attrs = append(attrs, attr)
+		}
+		el.Attr = attrs
+	}
+}
+
+// isScriptURL checks if v is a URL running a script when it's followed or loaded.
+func isScriptURL(v string) bool {
+	v = strings.ToLower(strings.Join(strings.Fields(v), ""))
+	return strings.HasPrefix(v, "javascript:") || strings.HasPrefix(v, "vbscript:")
+}
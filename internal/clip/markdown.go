@@ -0,0 +1,354 @@
+package clip
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/go-shiori/dom"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+var (
+	whitespace       = regexp.MustCompile(`\s+`)
+	markdownEscaper  = strings.NewReplacer(`\`, `\\`, "*", `\*`, "_", `\_`, "`", "\\`", "[", `\[`, "]", `\]`)
+	listItemMarker   = regexp.MustCompile(`^(-|\d+\.) `)
+	codeLanguageAttr = regexp.MustCompile(`(?:^|\s)(?:language|lang)-(\S+)`)
+
+	// blockMarker matches text at the start of a line which would be parsed as heading, block quote,
+	// bullet list or setext underline, orderedListMarker as ordered list.
+	blockMarker       = regexp.MustCompile(`^(?:#|>|[-+](?: |$)|=+$|-+$)`)
+	orderedListMarker = regexp.MustCompile(`^\d{1,9}[.)](?: |$)`)
+)
+
+// skippedElements are elements which are not converted at all.
+var skippedElements = map[atom.Atom]bool{
+	atom.Script: true, atom.Style: true, atom.Noscript: true, atom.Template: true,
+	atom.Iframe: true, atom.Svg: true, atom.Button: true, atom.Form: true, atom.Input: true,
+}
+
+// blockElements are elements which are rendered as separate Markdown blocks.
+var blockElements = map[atom.Atom]bool{
+	atom.Address: true, atom.Article: true, atom.Aside: true, atom.Blockquote: true, atom.Details: true,
+	atom.Dd: true, atom.Div: true, atom.Dl: true, atom.Dt: true, atom.Fieldset: true, atom.Figcaption: true,
+	atom.Figure: true, atom.Footer: true, atom.H1: true, atom.H2: true, atom.H3: true, atom.H4: true,
+	atom.H5: true, atom.H6: true, atom.Header: true, atom.Hr: true, atom.Li: true, atom.Main: true,
+	atom.Nav: true, atom.Ol: true, atom.P: true, atom.Pre: true, atom.Section: true, atom.Summary: true,
+	atom.Table: true, atom.Ul: true, atom.Body: true, atom.Html: true,
+}
+
+// HTMLToMarkdown converts the given HTML content to CommonMark with GitHub flavored tables and strikethrough.
+func HTMLToMarkdown(content string) (string, error) {
+	doc, err := dom.FastParse(strings.NewReader(content))
+	if err != nil {
+		return "", fmt.Errorf("failed to parse HTML: %w", err)
+	}
+
+	md := strings.Join(markdownBlocks(doc), "\n\n")
+	if md == "" {
+		return "", nil
+	}
+	return md + "\n", nil
+}
+
+// markdownBlocks converts the children of n into Markdown blocks.
+// Consecutive inline children are collected into a single paragraph.
+func markdownBlocks(n *html.Node) []string {
+	var blocks []string
+	var inline strings.Builder
+
+	flush := func() {
+		if p := normalizeParagraph(inline.String()); p != "" {
+			blocks = append(blocks, p)
+		}
+		inline.Reset()
+	}
+
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == html.ElementNode && (blockElements[child.DataAtom] || child.DataAtom == atom.Head) {
+			flush()
+			blocks = append(blocks, markdownBlock(child)...)
+			continue
+		}
+		inline.WriteString(markdownInline(child))
+	}
+	flush()
+
+	return blocks
+}
+
+// markdownBlock converts the block element n into Markdown blocks.
+func markdownBlock(n *html.Node) []string {
+	switch n.DataAtom {
+	case atom.Head:
+		return nil
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		text := normalizeInline(markdownInlineChildren(n))
+		if text == "" {
+			return nil
+		}
+		level := int(n.Data[1] - '0')
+		return []string{strings.Repeat("#", level) + " " + text}
+	case atom.P, atom.Dt, atom.Summary, atom.Figcaption:
+		if p := normalizeParagraph(markdownInlineChildren(n)); p != "" {
+			return []string{p}
+		}
+		return nil
+	case atom.Hr:
+		return []string{"---"}
+	case atom.Pre:
+		return []string{markdownCodeBlock(n)}
+	case atom.Blockquote:
+		return []string{prefixLines(strings.Join(markdownBlocks(n), "\n\n"), "> ", "> ")}
+	case atom.Ul, atom.Ol:
+		if list := markdownList(n); list != "" {
+			return []string{list}
+		}
+		return nil
+	case atom.Table:
+		if table := markdownTable(n); table != "" {
+			return []string{table}
+		}
+		return nil
+	default:
+		return markdownBlocks(n)
+	}
+}
+
+// markdownInline converts the inline node n into Markdown.
+func markdownInline(n *html.Node) string {
+	switch n.Type {
+	case html.TextNode:
+		return markdownEscaper.Replace(whitespace.ReplaceAllString(n.Data, " "))
+	case html.ElementNode:
+	default:
+		return ""
+	}
+
+	if skippedElements[n.DataAtom] {
+		return ""
+	}
+
+	switch n.DataAtom {
+	case atom.Br:
+		return "\n"
+	case atom.Strong, atom.B:
+		return wrapInline(markdownInlineChildren(n), "**")
+	case atom.Em, atom.I:
+		return wrapInline(markdownInlineChildren(n), "*")
+	case atom.Del, atom.S, atom.Strike:
+		return wrapInline(markdownInlineChildren(n), "~~")
+	case atom.Code, atom.Kbd, atom.Samp:
+		return markdownCodeSpan(dom.TextContent(n))
+	case atom.A:
+		text := normalizeInline(markdownInlineChildren(n))
+		href := strings.TrimSpace(dom.GetAttribute(n, "href"))
+		if href == "" || strings.HasPrefix(href, "javascript:") {
+			return text
+		}
+		if text == "" {
+			text = markdownEscaper.Replace(href)
+		}
+		return fmt.Sprintf("[%s](%s)", text, markdownURL(href))
+	case atom.Img:
+		src := strings.TrimSpace(dom.GetAttribute(n, "src"))
+		if src == "" {
+			return ""
+		}
+		alt := normalizeInline(markdownEscaper.Replace(dom.GetAttribute(n, "alt")))
+		return fmt.Sprintf("![%s](%s)", alt, markdownURL(src))
+	default:
+		// block elements nested in inline elements are rendered inline
+		return markdownInlineChildren(n)
+	}
+}
+
+func markdownInlineChildren(n *html.Node) string {
+	var sb strings.Builder
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		sb.WriteString(markdownInline(child))
+	}
+	return sb.String()
+}
+
+func markdownCodeBlock(n *html.Node) string {
+	var lang string
+	if m := codeLanguageAttr.FindStringSubmatch(dom.ClassName(n)); m != nil {
+		lang = m[1]
+	} else if code := dom.QuerySelector(n, "code"); code != nil {
+		if m := codeLanguageAttr.FindStringSubmatch(dom.ClassName(code)); m != nil {
+			lang = m[1]
+		}
+	}
+
+	code := strings.Trim(dom.TextContent(n), "\n")
+	fence := "```"
+	for strings.Contains(code, fence) {
+		fence += "`"
+	}
+	return fence + lang + "\n" + code + "\n" + fence
+}
+
+func markdownCodeSpan(code string) string {
+	code = whitespace.ReplaceAllString(code, " ")
+	if strings.TrimSpace(code) == "" {
+		return ""
+	}
+	delim := "`"
+	for strings.Contains(code, delim) {
+		delim += "`"
+	}
+	if strings.HasPrefix(code, "`") || strings.HasSuffix(code, "`") {
+		code = " " + code + " "
+	}
+	return delim + code + delim
+}
+
+func markdownList(n *html.Node) string {
+	var items []string
+	index := 1
+	if n.DataAtom == atom.Ol {
+		if start := dom.GetAttribute(n, "start"); start != "" {
+			fmt.Sscanf(start, "%d", &index)
+		}
+	}
+
+	for li := n.FirstChild; li != nil; li = li.NextSibling {
+		if li.Type != html.ElementNode || li.DataAtom != atom.Li {
+			continue
+		}
+
+		marker := "- "
+		if n.DataAtom == atom.Ol {
+			marker = fmt.Sprintf("%d. ", index)
+			index++
+		}
+
+		// nested lists directly follow the item text to keep the list tight
+		var content string
+		for i, block := range markdownBlocks(li) {
+			switch {
+			case i == 0:
+				content = block
+			case listItemMarker.MatchString(block):
+				content += "\n" + block
+			default:
+				content += "\n\n" + block
+			}
+		}
+		items = append(items, prefixLines(content, marker, strings.Repeat(" ", len(marker))))
+	}
+	return strings.Join(items, "\n")
+}
+
+func markdownTable(n *html.Node) string {
+	var rows [][]string
+	var header bool
+	for _, tr := range dom.QuerySelectorAll(n, "tr") {
+		var cells []string
+		for cell := tr.FirstChild; cell != nil; cell = cell.NextSibling {
+			if cell.Type != html.ElementNode || (cell.DataAtom != atom.Td && cell.DataAtom != atom.Th) {
+				continue
+			}
+			if len(rows) == 0 && cell.DataAtom == atom.Th {
+				header = true
+			}
+			text := normalizeInline(strings.ReplaceAll(markdownInlineChildren(cell), "\n", " "))
+			cells = append(cells, strings.ReplaceAll(text, "|", `\|`))
+		}
+		if len(cells) > 0 {
+			rows = append(rows, cells)
+		}
+	}
+	if len(rows) == 0 {
+		return ""
+	}
+
+	columns := 0
+	for _, row := range rows {
+		columns = max(columns, len(row))
+	}
+
+	// Markdown tables require a header row, use an empty one if the table has none
+	if !header {
+		rows = append([][]string{make([]string, columns)}, rows...)
+	}
+
+	lines := make([]string, 0, len(rows)+1)
+	for i, row := range rows {
+		for len(row) < columns {
+			row = append(row, "")
+		}
+		lines = append(lines, "| "+strings.Join(row, " | ")+" |")
+		if i == 0 {
+			lines = append(lines, "|"+strings.Repeat(" --- |", columns))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// markdownURL makes the URL safe to be used as link destination.
+func markdownURL(u string) string {
+	if strings.ContainsAny(u, " ()<>") {
+		return "<" + strings.NewReplacer("<", "%3C", ">", "%3E").Replace(u) + ">"
+	}
+	return u
+}
+
+func wrapInline(text, delim string) string {
+	trimmed := strings.TrimSpace(text)
+	if trimmed == "" {
+		return text
+	}
+	// keep surrounding whitespace outside of the delimiters, otherwise the emphasis is not recognized
+	leading := text[:len(text)-len(strings.TrimLeft(text, " "))]
+	trailing := text[len(strings.TrimRight(text, " ")):]
+	return leading + delim + trimmed + delim + trailing
+}
+
+// normalizeInline collapses whitespace in single-line inline Markdown.
+func normalizeInline(text string) string {
+	return strings.TrimSpace(whitespace.ReplaceAllString(text, " "))
+}
+
+// normalizeParagraph collapses whitespace in a paragraph while keeping hard line breaks.
+func normalizeParagraph(text string) string {
+	lines := strings.Split(text, "\n")
+	kept := lines[:0]
+	for _, line := range lines {
+		if line = normalizeInline(line); line != "" {
+			kept = append(kept, escapeBlockMarker(line))
+		}
+	}
+	return strings.Join(kept, "  \n")
+}
+
+// escapeBlockMarker escapes the start of a paragraph line, so that it isn't parsed as another block.
+// Leading * and _ are already escaped with the rest of the text.
+func escapeBlockMarker(line string) string {
+	if blockMarker.MatchString(line) {
+		return `\` + line
+	}
+	if m := orderedListMarker.FindString(line); m != "" {
+		i := strings.IndexAny(m, ".)")
+		return line[:i] + `\` + line[i:]
+	}
+	return line
+}
+
+// prefixLines prefixes the first line of text with first and all other non-empty lines with rest.
+func prefixLines(text, first, rest string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		switch {
+		case i == 0:
+			lines[i] = first + line
+		case line != "":
+			lines[i] = rest + line
+		case strings.TrimSpace(rest) != "":
+			lines[i] = strings.TrimRight(rest, " ")
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,39 @@
+package clip_test
+
+import (
+	"testing"
+
+	"github.com/timofurrer/influss/internal/clip"
+)
+
+func TestHTMLToMarkdown(t *testing.T) {
+	tests := []struct {
+		name string
+		html string
+		want string
+	}{
+		{"heading", `<h2>Heading</h2><p>Text</p>`, "## Heading\n\nText\n"},
+		{"list", `<ul><li>one</li><li>two</li></ul><ol start="3"><li>three</li></ol>`, "- one\n- two\n\n3. three\n"},
+		{"inline", `<p><strong>bold</strong>, <em>emphasis</em> and <a href="https://example.com">a link</a></p>`, "**bold**, *emphasis* and [a link](https://example.com)\n"},
+		{"escaped inline markers", `<p>2*3 is not_emphasis [or a link]</p>`, "2\\*3 is not\\_emphasis \\[or a link\\]\n"},
+		{"escaped heading", `<p># not a heading</p>`, "\\# not a heading\n"},
+		{"escaped block quote", `<p>> not a quote</p>`, "\\> not a quote\n"},
+		{"escaped bullet lists", `<p>- minus</p><p>+ plus</p><p>* star</p>`, "\\- minus\n\n\\+ plus\n\n\\* star\n"},
+		{"escaped ordered lists", `<p>1. not list</p><p>2) neither</p>`, "1\\. not list\n\n2\\) neither\n"},
+		{"escaped setext underlines", `<p>Not a heading<br>===<br>---</p>`, "Not a heading  \n\\===  \n\\---\n"},
+		{"escaped markers after line breaks", `<p>Text<br># not a heading<br>1. not list</p>`, "Text  \n\\# not a heading  \n1\\. not list\n"},
+		{"escaped markers in list items", `<ul><li># not a heading</li></ul>`, "- \\# not a heading\n"},
+		{"markers within text", `<p>C# and -5 degrees, 1.5 times &gt; 1</p>`, "C# and -5 degrees, 1.5 times > 1\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := clip.HTMLToMarkdown(tt.html)
+			if err != nil {
+				t.Fatalf("failed to convert HTML: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package clip
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+// excerptLength is the maximum length in runes of an excerpt generated from the plain text content.
+const excerptLength = 200
+
+// wordsPerMinute is the average adult reading speed used to estimate reading time.
+const wordsPerMinute = 200
+
+// stopwords are very common words per language used as a cheap signal for language detection.
+var stopwords = map[string][]string{
+	"en": {"the", "and", "of", "to", "is", "in", "that", "it", "for", "with", "was", "on", "are", "this", "be"},
+	"de": {"der", "die", "und", "das", "ist", "nicht", "ein", "eine", "zu", "den", "mit", "sich", "auf", "ich", "dem"},
+	"fr": {"le", "la", "les", "et", "est", "des", "une", "un", "du", "que", "pour", "dans", "pas", "qui", "sur"},
+	"es": {"el", "los", "las", "y", "es", "una", "del", "que", "por", "para", "con", "no", "se", "como", "su"},
+	"it": {"il", "di", "che", "e", "non", "per", "una", "sono", "della", "con", "del", "gli", "anche", "si", "come"},
+	"nl": {"de", "het", "een", "en", "van", "is", "niet", "dat", "op", "te", "zijn", "voor", "met", "ook", "maar"},
+	"pt": {"o", "os", "as", "e", "um", "uma", "do", "da", "não", "que", "para", "com", "por", "se", "mais"},
+}
+
+// stopwordLanguages is the reverse index of stopwords.
+var stopwordLanguages = func() map[string][]string {
+	idx := make(map[string][]string)
+	for lang, words := range stopwords {
+		for _, w := range words {
+			idx[w] = append(idx[w], lang)
+		}
+	}
+	return idx
+}()
+
+// Metadata is derived from the plain text content of a clip.
+type Metadata struct {
+	WordCount          int
+	ReadingTimeMinutes int
+	Language           string
+}
+
+// ComputeMetadata derives the word count, estimated reading time and language from the given text.
+// The language is an ISO 639-1 code or empty if it cannot be determined.
+func ComputeMetadata(text string) Metadata {
+	words := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r) && r != '\'' && r != '-'
+	})
+
+	return Metadata{
+		WordCount:          len(words),
+		ReadingTimeMinutes: readingTime(len(words)),
+		Language:           detectLanguage(words),
+	}
+}
+
+// WithMetadata sets the metadata derived from the plain text content on the clip.
+// The language from the page itself takes precedence over the detected one.
+func (c *Clip) WithMetadata() {
+	m := ComputeMetadata(c.PlainTextContent)
+	c.WordCount = m.WordCount
+	c.ReadingTimeMinutes = m.ReadingTimeMinutes
+	if c.Language == "" {
+		c.Language = m.Language
+	}
+}
+
+func readingTime(wordCount int) int {
+	if wordCount == 0 {
+		return 0
+	}
+	return int(math.Ceil(float64(wordCount) / wordsPerMinute))
+}
+
+func detectLanguage(words []string) string {
+	scores := make(map[string]int)
+	for _, w := range words {
+		for _, lang := range stopwordLanguages[strings.ToLower(w)] {
+			scores[lang]++
+		}
+	}
+
+	var best string
+	var bestScore int
+	for lang, score := range scores {
+		if score > bestScore || (score == bestScore && lang < best) {
+			best, bestScore = lang, score
+		}
+	}
+
+	// require a minimum amount of evidence to avoid guessing on short texts
+	if bestScore < 3 {
+		return ""
+	}
+	return best
+}
+
+// normalizeLanguage reduces language tags like `en-US` to their primary subtag.
+func normalizeLanguage(lang string) string {
+	lang = strings.ToLower(strings.TrimSpace(lang))
+	if i := strings.IndexAny(lang, "-_"); i >= 0 {
+		lang = lang[:i]
+	}
+	return lang
+}
+
+// excerpt returns the beginning of the given text, cut at a word boundary.
+func excerpt(text string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	runes := []rune(text)
+	if len(runes) <= excerptLength {
+		return text
+	}
+	cut := string(runes[:excerptLength])
+	if i := strings.LastIndex(cut, " "); i > 0 {
+		cut = cut[:i]
+	}
+	return cut + "…"
+}
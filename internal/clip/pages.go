@@ -0,0 +1,185 @@
+package clip
+
+import (
+	"log/slog"
+	nurl "net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/go-shiori/dom"
+	"golang.org/x/net/html"
+)
+
+var (
+	// nextLinkTexts are the texts of links commonly used to navigate to the next page.
+	nextLinkTexts = []string{"next", "next page", "continue", "›", "»", "→", "weiter", "nächste seite", "suivant", "page suivante", "siguiente"}
+	// nextLinkClasses are the classes of links commonly used to navigate to the next page.
+	nextLinkClasses = regexp.MustCompile(`(?i)(^|\s)(next|next-page|pagination-next|page-next)(\s|$)`)
+	// paginationContainers matches the classes and ids of elements commonly containing pagination links.
+	paginationContainers = regexp.MustCompile(`(?i)pagination|pager|paging|page-numbers`)
+	// pageQueryParams are the query parameters commonly used for the page number.
+	pageQueryParams = []string{"page", "p", "pg"}
+	// pagePathSegment matches a trailing page number in a URL path, like `/2/`, `/page/2` or `/2`.
+	pagePathSegment = regexp.MustCompile(`/(page/)?(\d{1,3})/?$`)
+)
+
+// stitchPages follows the links to the next pages of a multi-page article on the same host
+// and appends their content to the clip.
+func (c *Clipper) stitchPages(log *slog.Logger, clip *Clip, doc *html.Node, pageURL *nurl.URL, rule *Rule) {
+	visited := map[string]bool{normalizePageURL(pageURL): true}
+
+	for page := 2; page <= c.maxPages; page++ {
+		nextURL := findNextPageURL(doc, pageURL)
+		if nextURL == nil || nextURL.Host != pageURL.Host || visited[normalizePageURL(nextURL)] {
+			return
+		}
+		visited[normalizePageURL(nextURL)] = true
+
+		nextDoc, nextPageURL, err := c.fetch(nextURL)
+		if err != nil {
+			log.Warn("Unable to fetch next page of article", slog.String("page_url", nextURL.String()), slog.String("error", err.Error()))
+			return
+		}
+
+		next, err := c.extract(nextDoc, nextPageURL, rule)
+		if err != nil {
+			log.Warn("Unable to extract next page of article", slog.String("page_url", nextURL.String()), slog.String("error", err.Error()))
+			return
+		}
+
+		clip.HTMLContent += "\n" + renumberPage(next.HTMLContent, page)
+		clip.PlainTextContent = strings.TrimSpace(clip.PlainTextContent) + "\n\n" + strings.TrimSpace(next.PlainTextContent)
+
+		doc, pageURL = nextDoc, nextPageURL
+	}
+}
+
+// readabilityPageID is the id of the element readability wraps the content of every page in.
+const readabilityPageID = `id="readability-page-1"`
+
+// renumberPage gives the page wrapper of readability in the content the number of the page,
+// because ids must be unique in the stitched content.
+func renumberPage(content string, page int) string {
+	return strings.Replace(content, readabilityPageID, `id="readability-page-`+strconv.Itoa(page)+`"`, 1)
+}
+
+// findNextPageURL finds the URL of the next page of the article in doc.
+// It prefers explicit `rel=next` links over links which look like pagination links.
+func findNextPageURL(doc *html.Node, pageURL *nurl.URL) *nurl.URL {
+	for _, n := range dom.QuerySelectorAll(doc, `link[rel~="next"][href], a[rel~="next"][href]`) {
+		if u := resolvePageLink(pageURL, dom.GetAttribute(n, "href")); u != nil {
+			return u
+		}
+	}
+
+	expected := expectedNextPageURLs(pageURL)
+	for _, n := range dom.QuerySelectorAll(doc, "a[href]") {
+		u := resolvePageLink(pageURL, dom.GetAttribute(n, "href"))
+		if u == nil || u.Host != pageURL.Host {
+			continue
+		}
+
+		text := strings.ToLower(strings.Trim(strings.TrimSpace(dom.TextContent(n)), "›»→ "))
+		if text == "" {
+			text = strings.TrimSpace(dom.TextContent(n))
+		}
+		switch {
+		case expected[normalizePageURL(u)] && (isNumber(text) || inPagination(n)):
+			return u
+		case isNextLinkText(text) || nextLinkClasses.MatchString(dom.ClassName(n)):
+			if u.Path == pageURL.Path && u.RawQuery == pageURL.RawQuery {
+				continue
+			}
+			return u
+		}
+	}
+	return nil
+}
+
+// expectedNextPageURLs returns the normalized URLs the next page of the given page URL commonly has.
+func expectedNextPageURLs(pageURL *nurl.URL) map[string]bool {
+	expected := make(map[string]bool)
+	add := func(u *nurl.URL) {
+		expected[normalizePageURL(u)] = true
+	}
+
+	query := pageURL.Query()
+	for _, param := range pageQueryParams {
+		current := 1
+		if v := query.Get(param); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				continue
+			}
+			current = n
+		}
+		u := *pageURL
+		q := pageURL.Query()
+		q.Set(param, strconv.Itoa(current+1))
+		u.RawQuery = q.Encode()
+		add(&u)
+	}
+
+	if m := pagePathSegment.FindStringSubmatch(pageURL.Path); m != nil {
+		current, _ := strconv.Atoi(m[2])
+		u := *pageURL
+		u.Path = pagePathSegment.ReplaceAllString(pageURL.Path, "/${1}"+strconv.Itoa(current+1))
+		add(&u)
+	} else {
+		for _, suffix := range []string{"2", "page/2"} {
+			u := *pageURL
+			u.Path = strings.TrimSuffix(pageURL.Path, "/") + "/" + suffix
+			add(&u)
+		}
+	}
+
+	return expected
+}
+
+func isNextLinkText(text string) bool {
+	for _, t := range nextLinkTexts {
+		if text == t {
+			return true
+		}
+	}
+	return false
+}
+
+func isNumber(text string) bool {
+	_, err := strconv.Atoi(text)
+	return err == nil
+}
+
+// inPagination checks if n is part of an element which looks like a pagination container.
+func inPagination(n *html.Node) bool {
+	for p, depth := n.Parent, 0; p != nil && depth < 4; p, depth = p.Parent, depth+1 {
+		if p.Type == html.ElementNode && paginationContainers.MatchString(dom.ClassName(p)+" "+dom.ID(p)) {
+			return true
+		}
+	}
+	return false
+}
+
+func resolvePageLink(pageURL *nurl.URL, href string) *nurl.URL {
+	href = strings.TrimSpace(href)
+	if href == "" || strings.HasPrefix(href, "#") || strings.HasPrefix(strings.ToLower(href), "javascript:") {
+		return nil
+	}
+	u, err := pageURL.Parse(href)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil
+	}
+	u.Fragment = ""
+	return u
+}
+
+// normalizePageURL returns a canonical representation of the given URL to compare page URLs.
+func normalizePageURL(u *nurl.URL) string {
+	n := *u
+	n.Fragment = ""
+	n.Host = strings.ToLower(n.Host)
+	n.Path = strings.TrimSuffix(n.Path, "/")
+	n.RawQuery = n.Query().Encode()
+	return n.String()
+}
@@ -0,0 +1,225 @@
+package clip
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/andybalholm/cascadia"
+	"github.com/go-shiori/dom"
+	"golang.org/x/net/html"
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed rules/*.yaml
+var builtinRulesFS embed.FS
+
+const rulesFileExt = ".yaml"
+
+// Rule defines how to extract an article from the pages of a specific site.
+//
+// Rules are loaded from YAML files named after the domain they apply to,
+// e.g. `example.com.yaml`. A file name starting with a dot, e.g. `.example.com.yaml`,
+// applies to the domain and all its subdomains.
+type Rule struct {
+	// Title are CSS selectors of which the first match is used as article title.
+	Title []string `yaml:"title"`
+	// Author are CSS selectors of which the first match is used as article author.
+	Author []string `yaml:"author"`
+	// Body are CSS selectors of which the first match is used as article content instead of
+	// the content found by readability.
+	Body []string `yaml:"body"`
+	// Strip are CSS selectors of elements that are removed before the article is extracted.
+	Strip []string `yaml:"strip"`
+	// SinglePageLink are CSS selectors of a link to the single page view of an article.
+	// If one matches, the linked page is clipped instead.
+	SinglePageLink []string `yaml:"single_page_link"`
+	// RewriteURL are rewrites applied to the URL before it is fetched,
+	// e.g. to directly fetch the single page view of an article.
+	RewriteURL []URLRewrite `yaml:"rewrite_url"`
+
+	title, author, body, strip, singlePageLink []cascadia.Selector
+}
+
+// URLRewrite replaces the matches of the Pattern regular expression in a URL with Replacement.
+type URLRewrite struct {
+	Pattern     string `yaml:"pattern"`
+	Replacement string `yaml:"replacement"`
+
+	re *regexp.Regexp
+}
+
+// Rules is a set of site-specific rules by domain.
+type Rules struct {
+	rules map[string]*Rule
+}
+
+// LoadRules loads the built-in rules and, if dir is not empty, the rules from the given directory.
+// Rules from the directory override built-in rules for the same domain.
+func LoadRules(dir string) (*Rules, error) {
+	rules := &Rules{rules: make(map[string]*Rule)}
+
+	builtin, err := fs.Sub(builtinRulesFS, "rules")
+	if err != nil {
+		return nil, err
+	}
+	if err := rules.load(builtin); err != nil {
+		return nil, fmt.Errorf("failed to load built-in rules: %w", err)
+	}
+
+	if dir != "" {
+		if err := rules.load(os.DirFS(dir)); err != nil {
+			return nil, fmt.Errorf("failed to load rules from %s: %w", dir, err)
+		}
+	}
+
+	return rules, nil
+}
+
+func (r *Rules) load(fsys fs.FS) error {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || path.Ext(entry.Name()) != rulesFileExt {
+			continue
+		}
+
+		data, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return err
+		}
+
+		rule := &Rule{}
+		if err := yaml.Unmarshal(data, rule); err != nil {
+			return fmt.Errorf("failed to parse rule file %s: %w", entry.Name(), err)
+		}
+		if err := rule.compile(); err != nil {
+			return fmt.Errorf("invalid rule file %s: %w", entry.Name(), err)
+		}
+
+		r.rules[strings.ToLower(strings.TrimSuffix(entry.Name(), rulesFileExt))] = rule
+	}
+	return nil
+}
+
+// ForHost returns the rule for the given host or nil if there is none.
+// An exact match takes precedence over rules for parent domains.
+func (r *Rules) ForHost(host string) *Rule {
+	if r == nil {
+		return nil
+	}
+
+	host = strings.ToLower(host)
+	if rule, ok := r.rules[host]; ok {
+		return rule
+	}
+	if rule, ok := r.rules[strings.TrimPrefix(host, "www.")]; ok {
+		return rule
+	}
+
+	for domain := host; domain != ""; {
+		if rule, ok := r.rules["."+domain]; ok {
+			return rule
+		}
+		_, parent, found := strings.Cut(domain, ".")
+		if !found {
+			break
+		}
+		domain = parent
+	}
+	return nil
+}
+
+func (r *Rule) compile() error {
+	var errs []error
+	compile := func(selectors []string) []cascadia.Selector {
+		compiled := make([]cascadia.Selector, 0, len(selectors))
+		for _, s := range selectors {
+			sel, err := cascadia.Compile(s)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("invalid selector %q: %w", s, err))
+				continue
+			}
+			compiled = append(compiled, sel)
+		}
+		return compiled
+	}
+
+	r.title = compile(r.Title)
+	r.author = compile(r.Author)
+	r.body = compile(r.Body)
+	r.strip = compile(r.Strip)
+	r.singlePageLink = compile(r.SinglePageLink)
+
+	for i := range r.RewriteURL {
+		re, err := regexp.Compile(r.RewriteURL[i].Pattern)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid URL rewrite pattern %q: %w", r.RewriteURL[i].Pattern, err))
+			continue
+		}
+		r.RewriteURL[i].re = re
+	}
+
+	return errors.Join(errs...)
+}
+
+// rewriteURL applies the URL rewrites of the rule to the given URL.
+func (r *Rule) rewriteURL(u string) string {
+	for _, rw := range r.RewriteURL {
+		u = rw.re.ReplaceAllString(u, rw.Replacement)
+	}
+	return u
+}
+
+// singlePageURL returns the link target of the single page view link in doc, if any.
+func (r *Rule) singlePageURL(doc *html.Node) string {
+	if n := queryFirst(doc, r.singlePageLink); n != nil {
+		return dom.GetAttribute(n, "href")
+	}
+	return ""
+}
+
+// stripElements removes all elements matching the strip selectors from doc.
+func (r *Rule) stripElements(doc *html.Node) {
+	for _, sel := range r.strip {
+		for _, n := range cascadia.QueryAll(doc, sel) {
+			if n.Parent != nil {
+				n.Parent.RemoveChild(n)
+			}
+		}
+	}
+}
+
+// extract overrides the fields of the article extracted by readability with
+// the content selected by the rule.
+// The selected content doesn't go through readability, therefore it's sanitized.
+func (r *Rule) extract(doc *html.Node, c *Clip) {
+	if n := queryFirst(doc, r.title); n != nil {
+		c.Title = strings.TrimSpace(dom.TextContent(n))
+	}
+	if n := queryFirst(doc, r.author); n != nil {
+		c.Author = strings.TrimSpace(dom.TextContent(n))
+	}
+	if n := queryFirst(doc, r.body); n != nil {
+		sanitize(n)
+		c.HTMLContent = dom.OuterHTML(n)
+		c.PlainTextContent = strings.TrimSpace(dom.TextContent(n))
+	}
+}
+
+func queryFirst(doc *html.Node, selectors []cascadia.Selector) *html.Node {
+	for _, sel := range selectors {
+		if n := cascadia.Query(doc, sel); n != nil {
+			return n
+		}
+	}
+	return nil
+}
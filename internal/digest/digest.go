@@ -0,0 +1,221 @@
+// Package digest sends digests of the stored clips by email on a schedule,
+// either as summary or with the clips attached, e.g. to send them to a Kindle.
+package digest
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/timofurrer/influss/internal/clip"
+	"github.com/timofurrer/influss/internal/epub"
+	"github.com/timofurrer/influss/internal/jsonfile"
+	"github.com/timofurrer/influss/internal/store"
+)
+
+//go:embed templates/*.html
+var templatesFS embed.FS
+
+var templates = template.Must(template.New("").Funcs(template.FuncMap{
+	"content": func(c *clip.Clip) template.HTML {
+		return template.HTML(c.HTMLContent)
+	},
+}).ParseFS(templatesFS, "templates/*.html"))
+
+// Format is how the clips are delivered.
+type Format string
+
+const (
+	// FormatSummary sends an email listing the clips with their excerpts.
+	FormatSummary Format = "summary"
+	// FormatEPUB attaches the clips as EPUB book.
+	FormatEPUB Format = "epub"
+	// FormatHTML attaches the clips as single HTML document.
+	FormatHTML Format = "html"
+)
+
+var Formats = []Format{FormatSummary, FormatEPUB, FormatHTML}
+
+type Config struct {
+	From    string
+	To      []string
+	Subject string
+	Format  Format
+	// Query selects the clips to send, clips which were already sent are skipped.
+	Query store.Query
+	// Limit is the maximum number of clips in a digest, 0 means no limit.
+	Limit int
+	// StateFile records which clips were already sent.
+	StateFile string
+}
+
+type Digest struct {
+	log    *slog.Logger
+	cfg    Config
+	store  store.Store
+	mailer *Mailer
+}
+
+func New(log *slog.Logger, cfg Config, store store.Store, mailer *Mailer) *Digest {
+	return &Digest{log: log, cfg: cfg, store: store, mailer: mailer}
+}
+
+// state is persisted in the state file.
+type state struct {
+	// Sent maps the URLs of the sent clips to the time they were sent.
+	Sent       map[string]time.Time `json:"sent"`
+	LastSentAt time.Time            `json:"last_sent_at"`
+}
+
+// Run sends a digest whenever the schedule is due until the context is canceled.
+func (d *Digest) Run(ctx context.Context, schedule *Schedule) {
+	for {
+		next := schedule.Next(time.Now())
+		if next.IsZero() {
+			d.log.Error("Digest schedule never fires, stopping")
+			return
+		}
+		d.log.Info("Scheduled next digest", slog.Time("at", next))
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Until(next)):
+		}
+
+		sent, err := d.Send(ctx)
+		if err != nil {
+			d.log.Error("Failed to send digest", slog.String("error", err.Error()))
+			continue
+		}
+		d.log.Info("Sent digest", slog.Int("clips", sent))
+	}
+}
+
+// Send sends a digest of the clips which weren't sent yet and returns how many clips it contained.
+// Nothing is sent if there are no new clips.
+func (d *Digest) Send(ctx context.Context) (int, error) {
+	st, err := d.loadState()
+	if err != nil {
+		return 0, fmt.Errorf("failed to load digest state: %w", err)
+	}
+
+	q := d.cfg.Query
+	q.Ascending = true
+	clips, _, err := d.store.List(ctx, q)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list clips: %w", err)
+	}
+
+	var pending []*clip.Clip
+	for _, c := range clips {
+		if _, sent := st.Sent[c.URL]; sent {
+			continue
+		}
+		pending = append(pending, c)
+		if d.cfg.Limit > 0 && len(pending) == d.cfg.Limit {
+			break
+		}
+	}
+	if len(pending) == 0 {
+		d.log.Info("No new clips for the digest")
+		return 0, nil
+	}
+
+	msg, err := d.message(ctx, pending)
+	if err != nil {
+		return 0, err
+	}
+	if err := d.mailer.Send(ctx, msg); err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	for _, c := range pending {
+		st.Sent[c.URL] = now
+	}
+	st.LastSentAt = now
+	if err := d.saveState(st); err != nil {
+		return len(pending), fmt.Errorf("digest was sent, but recording it failed: %w", err)
+	}
+	return len(pending), nil
+}
+
+type digestPage struct {
+	Subject string
+	Clips   []*clip.Clip
+	Format  Format
+}
+
+func (d *Digest) message(ctx context.Context, clips []*clip.Clip) (message, error) {
+	subject := fmt.Sprintf("%s: %d clip", d.cfg.Subject, len(clips))
+	if len(clips) != 1 {
+		subject += "s"
+	}
+	msg := message{from: d.cfg.From, to: d.cfg.To, subject: subject}
+	date := time.Now().Format(time.DateOnly)
+
+	var body bytes.Buffer
+	if err := templates.ExecuteTemplate(&body, "summary.html", digestPage{Subject: subject, Clips: clips, Format: d.cfg.Format}); err != nil {
+		return msg, fmt.Errorf("failed to render digest: %w", err)
+	}
+	msg.html = body.String()
+
+	switch d.cfg.Format {
+	case FormatEPUB:
+		b := epub.NewBuilder(d.log, epub.Config{Title: fmt.Sprintf("%s %s", d.cfg.Subject, date)})
+		for _, c := range clips {
+			b.WithClip(c)
+		}
+		var buf bytes.Buffer
+		if err := b.Write(ctx, &buf); err != nil {
+			return msg, fmt.Errorf("failed to build EPUB: %w", err)
+		}
+		msg.attachments = append(msg.attachments, attachment{
+			name:        fmt.Sprintf("influss-%s.epub", date),
+			contentType: "application/epub+zip",
+			data:        buf.Bytes(),
+		})
+	case FormatHTML:
+		var buf bytes.Buffer
+		if err := templates.ExecuteTemplate(&buf, "articles.html", digestPage{Subject: subject, Clips: clips}); err != nil {
+			return msg, fmt.Errorf("failed to render articles: %w", err)
+		}
+		msg.attachments = append(msg.attachments, attachment{
+			name:        fmt.Sprintf("influss-%s.html", date),
+			contentType: "text/html",
+			data:        buf.Bytes(),
+		})
+	}
+	return msg, nil
+}
+
+func (d *Digest) loadState() (*state, error) {
+	st := &state{Sent: make(map[string]time.Time)}
+	data, err := os.ReadFile(d.cfg.StateFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return st, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, st); err != nil {
+		return nil, err
+	}
+	if st.Sent == nil {
+		st.Sent = make(map[string]time.Time)
+	}
+	return st, nil
+}
+
+func (d *Digest) saveState(st *state) error {
+	return jsonfile.Write(d.cfg.StateFile, st)
+}
@@ -0,0 +1,253 @@
+// Package epub builds EPUB 3 books from clips, e.g. to read them offline on e-readers.
+package epub
+
+import (
+	"archive/zip"
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/rand"
+	"embed"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/go-shiori/dom"
+	"github.com/timofurrer/influss/internal/clip"
+	"golang.org/x/net/html"
+)
+
+//go:embed templates
+var templatesFS embed.FS
+
+var templates = template.Must(template.New("").Funcs(template.FuncMap{
+	"xml": xmlEscape,
+	"inc": func(i int) int { return i + 1 },
+}).ParseFS(templatesFS, "templates/*"))
+
+type Config struct {
+	Title    string
+	Author   string
+	Language string
+	// Client is used to fetch the images which are embedded into the book.
+	Client *http.Client
+	// MaxImageSize is the maximum size of a single image in bytes, larger images are left out.
+	MaxImageSize int64
+	// ImagesTimeout is the time to fetch all images of the book, the images not fetched by then are left out.
+	ImagesTimeout time.Duration
+}
+
+type Builder struct {
+	log   *slog.Logger
+	cfg   Config
+	clips []*clip.Clip
+}
+
+func NewBuilder(log *slog.Logger, cfg Config) *Builder {
+	cfg.Title = cmp.Or(cfg.Title, "influss")
+	if cfg.Client == nil {
+		cfg.Client = &http.Client{Timeout: 30 * time.Second}
+	}
+	cfg.MaxImageSize = cmp.Or(cfg.MaxImageSize, 5<<20)
+	cfg.ImagesTimeout = cmp.Or(cfg.ImagesTimeout, time.Minute)
+	return &Builder{log: log, cfg: cfg}
+}
+
+// WithClip adds the clip as next chapter to the book.
+func (b *Builder) WithClip(c *clip.Clip) {
+	b.clips = append(b.clips, c)
+}
+
+type book struct {
+	ID       string
+	Title    string
+	Author   string
+	Language string
+	Date     string
+	Modified string
+	Chapters []chapter
+	Images   []*image
+}
+
+type chapter struct {
+	ID                 string
+	File               string
+	Title              string
+	Author             string
+	SiteName           string
+	URL                string
+	Language           string
+	ReadingTimeMinutes int
+	Content            string
+}
+
+// bookFile is a file of the book rendered from a template.
+type bookFile struct {
+	name     string
+	template string
+	data     any
+}
+
+// Write writes the book as EPUB to w.
+// Images which can't be fetched within the images timeout are left out of the book.
+func (b *Builder) Write(ctx context.Context, w io.Writer) error {
+	now := time.Now().UTC()
+	bk := book{
+		ID:       newUUID(),
+		Title:    b.cfg.Title,
+		Author:   b.cfg.Author,
+		Language: cmp.Or(b.cfg.Language, b.commonLanguage(), "en"),
+		Date:     now.Format(time.DateOnly),
+		Modified: now.Format("2006-01-02T15:04:05Z"),
+	}
+
+	bodies := make([]*html.Node, len(b.clips))
+	var srcs []string
+	seen := make(map[string]bool)
+	for i, c := range b.clips {
+		body, err := parseContent(c)
+		if err != nil {
+			return fmt.Errorf("failed to convert clip %s: %w", c.URL, err)
+		}
+		bodies[i] = body
+		for _, img := range dom.GetElementsByTagName(body, "img") {
+			if src := dom.GetAttribute(img, "src"); src != "" && !seen[src] {
+				seen[src] = true
+				srcs = append(srcs, src)
+			}
+		}
+	}
+
+	imagesCtx, cancel := context.WithTimeout(ctx, b.cfg.ImagesTimeout)
+	defer cancel()
+	images, embedded := newImageFetcher(b.log, b.cfg.Client, b.cfg.MaxImageSize).fetchAll(imagesCtx, srcs)
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	for i, c := range b.clips {
+		content := chapterContent(bodies[i], images)
+		bk.Chapters = append(bk.Chapters, chapter{
+			ID:                 fmt.Sprintf("chapter-%04d", i+1),
+			File:               fmt.Sprintf("chapter-%04d.xhtml", i+1),
+			Title:              cmp.Or(c.Title, c.URL),
+			Author:             c.Author,
+			SiteName:           c.SiteName,
+			URL:                c.URL,
+			Language:           cmp.Or(c.Language, bk.Language),
+			ReadingTimeMinutes: c.ReadingTimeMinutes,
+			Content:            content,
+		})
+	}
+	bk.Images = embedded
+
+	zw := zip.NewWriter(w)
+	// the mimetype must be the first and an uncompressed file
+	mw, err := zw.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store, Modified: now})
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(mw, "application/epub+zip"); err != nil {
+		return err
+	}
+
+	files := []bookFile{
+		{"META-INF/container.xml", "container.xml", bk},
+		{"OEBPS/content.opf", "content.opf", bk},
+		{"OEBPS/nav.xhtml", "nav.xhtml", bk},
+		{"OEBPS/toc.ncx", "toc.ncx", bk},
+		{"OEBPS/style.css", "style.css", bk},
+	}
+	for _, c := range bk.Chapters {
+		files = append(files, bookFile{"OEBPS/" + c.File, "chapter.xhtml", c})
+	}
+	for _, f := range files {
+		fw, err := zw.CreateHeader(&zip.FileHeader{Name: f.name, Method: zip.Deflate, Modified: now})
+		if err != nil {
+			return err
+		}
+		if err := templates.ExecuteTemplate(fw, f.template, f.data); err != nil {
+			return fmt.Errorf("failed to render %s: %w", f.name, err)
+		}
+	}
+
+	for _, img := range bk.Images {
+		// images are already compressed
+		fw, err := zw.CreateHeader(&zip.FileHeader{Name: "OEBPS/" + img.File, Method: zip.Store, Modified: now})
+		if err != nil {
+			return err
+		}
+		if _, err := fw.Write(img.data); err != nil {
+			return err
+		}
+	}
+
+	return zw.Close()
+}
+
+// parseContent parses the HTML content of the clip and returns its body.
+func parseContent(c *clip.Clip) (*html.Node, error) {
+	doc, err := dom.FastParse(strings.NewReader(c.HTMLContent))
+	if err != nil {
+		return nil, err
+	}
+	if body := dom.QuerySelector(doc, "body"); body != nil {
+		return body, nil
+	}
+	return doc, nil
+}
+
+// chapterContent converts the parsed HTML content of a clip to XHTML with the embedded images,
+// images which weren't fetched are left out.
+func chapterContent(body *html.Node, images map[string]*image) string {
+	for _, img := range dom.GetElementsByTagName(body, "img") {
+		if embedded, ok := images[dom.GetAttribute(img, "src")]; ok {
+			dom.SetAttribute(img, "src", embedded.File)
+			continue
+		}
+		if img.Parent != nil {
+			img.Parent.RemoveChild(img)
+		}
+	}
+
+	var buf bytes.Buffer
+	for child := body.FirstChild; child != nil; child = child.NextSibling {
+		writeXHTML(&buf, child)
+	}
+	return buf.String()
+}
+
+// commonLanguage returns the most common language of the clips.
+func (b *Builder) commonLanguage() string {
+	counts := make(map[string]int)
+	var common string
+	for _, c := range b.clips {
+		if c.Language == "" {
+			continue
+		}
+		counts[c.Language]++
+		if counts[c.Language] > counts[common] {
+			common = c.Language
+		}
+	}
+	return common
+}
+
+func newUUID() string {
+	var u [16]byte
+	rand.Read(u[:])
+	u[6] = (u[6] & 0x0f) | 0x40
+	u[8] = (u[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:16])
+}
+
+func xmlEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
@@ -0,0 +1,102 @@
+package epub_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/timofurrer/influss/internal/clip"
+	"github.com/timofurrer/influss/internal/epub"
+)
+
+// png is enough of a PNG image for its type to be detected.
+const png = "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"
+
+func TestWriteFetchesImagesConcurrentlyUntilTimeout(t *testing.T) {
+	var running, maxRunning atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for m := maxRunning.Load(); n > m && !maxRunning.CompareAndSwap(m, n); m = maxRunning.Load() {
+		}
+
+		if strings.HasPrefix(r.URL.Path, "/slow") {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(10 * time.Second):
+			}
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+		io.WriteString(w, png)
+	}))
+	defer srv.Close()
+
+	// the slow images block two of the workers until the timeout
+	var clips []*clip.Clip
+	for i := range 8 {
+		content := fmt.Sprintf(`<p>Text</p><img src="%s/fast/%d.png">`, srv.URL, i)
+		if i < 2 {
+			content = fmt.Sprintf(`<img src="%s/slow/%d.png">`, srv.URL, i) + content
+		}
+		clips = append(clips, &clip.Clip{
+			URL:         fmt.Sprintf("https://example.com/%d", i),
+			Title:       fmt.Sprintf("Clip %d", i),
+			HTMLContent: content,
+		})
+	}
+
+	b := epub.NewBuilder(slog.New(slog.NewTextHandler(io.Discard, nil)), epub.Config{ImagesTimeout: 500 * time.Millisecond})
+	for _, c := range clips {
+		b.WithClip(c)
+	}
+	var buf bytes.Buffer
+	start := time.Now()
+	if err := b.Write(context.Background(), &buf); err != nil {
+		t.Fatalf("failed to write book: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("expected the images timeout to end fetching, took %s", elapsed)
+	}
+	if n := maxRunning.Load(); n < 2 || n > 4 {
+		t.Errorf("expected images to be fetched by up to 4 workers, got %d at the same time", n)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("failed to read book: %v", err)
+	}
+	var images int
+	var chapters strings.Builder
+	for _, f := range zr.File {
+		switch {
+		case strings.HasPrefix(f.Name, "OEBPS/images/"):
+			images++
+		case strings.HasPrefix(f.Name, "OEBPS/chapter-"):
+			r, err := f.Open()
+			if err != nil {
+				t.Fatal(err)
+			}
+			io.Copy(&chapters, r)
+			r.Close()
+		}
+	}
+	if images != 8 {
+		t.Errorf("expected the 8 fast images to be embedded, got %d", images)
+	}
+	if strings.Contains(chapters.String(), srv.URL) {
+		t.Errorf("expected the slow images to be left out, got %s", chapters.String())
+	}
+	if !strings.Contains(chapters.String(), `src="images/0001.png"`) {
+		t.Errorf("expected the fast images to be embedded, got %s", chapters.String())
+	}
+}
@@ -0,0 +1,236 @@
+package epub
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/html"
+)
+
+// droppedElements are removed including their content.
+var droppedElements = []string{
+	"script", "style", "noscript", "template", "iframe", "frame", "object", "embed", "applet",
+	"form", "input", "button", "select", "textarea", "canvas", "svg", "math",
+	"audio", "video", "source", "track", "head", "title", "meta", "link", "base",
+}
+
+// allowedElements are written as they are, other elements are replaced by their content.
+var allowedElements = []string{
+	"a", "abbr", "article", "aside", "b", "bdi", "bdo", "blockquote", "br", "caption", "cite", "code",
+	"col", "colgroup", "dd", "del", "dfn", "div", "dl", "dt", "em", "figcaption", "figure", "footer",
+	"h1", "h2", "h3", "h4", "h5", "h6", "header", "hr", "i", "img", "ins", "kbd", "li", "mark", "ol",
+	"p", "pre", "q", "rp", "rt", "ruby", "s", "samp", "section", "small", "span", "strong", "sub",
+	"sup", "table", "tbody", "td", "tfoot", "th", "thead", "time", "tr", "u", "ul", "var", "wbr",
+}
+
+var voidElements = []string{"br", "col", "hr", "img", "wbr"}
+
+var globalAttributes = []string{"title", "lang", "dir"}
+
+var allowedAttributes = map[string][]string{
+	"a":          {"href"},
+	"img":        {"src", "alt", "width", "height"},
+	"td":         {"colspan", "rowspan"},
+	"th":         {"colspan", "rowspan"},
+	"col":        {"span"},
+	"colgroup":   {"span"},
+	"ol":         {"start", "reversed"},
+	"blockquote": {"cite"},
+	"q":          {"cite"},
+	"del":        {"cite", "datetime"},
+	"ins":        {"cite", "datetime"},
+	"time":       {"datetime"},
+}
+
+// numericAttributes must be non-negative integers.
+var numericAttributes = []string{"width", "height", "colspan", "rowspan", "span", "start"}
+
+// writeXHTML writes the node as XHTML, keeping only elements and attributes which are valid in EPUB content documents.
+func writeXHTML(buf *bytes.Buffer, n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		buf.WriteString(xmlEscape(n.Data))
+		return
+	case html.ElementNode:
+	default:
+		return
+	}
+
+	tag := n.Data
+	if n.Namespace != "" || slices.Contains(droppedElements, tag) {
+		return
+	}
+	if !slices.Contains(allowedElements, tag) {
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			writeXHTML(buf, child)
+		}
+		return
+	}
+
+	buf.WriteString("<" + tag)
+	hasAlt := false
+	for _, a := range n.Attr {
+		if a.Namespace != "" || (!slices.Contains(globalAttributes, a.Key) && !slices.Contains(allowedAttributes[tag], a.Key)) {
+			continue
+		}
+		value, ok := attributeValue(a.Key, a.Val)
+		if !ok {
+			continue
+		}
+		hasAlt = hasAlt || a.Key == "alt"
+		fmt.Fprintf(buf, ` %s="%s"`, a.Key, xmlEscape(value))
+	}
+	if tag == "img" && !hasAlt {
+		buf.WriteString(` alt=""`)
+	}
+
+	if slices.Contains(voidElements, tag) {
+		buf.WriteString("/>")
+		return
+	}
+	buf.WriteString(">")
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		writeXHTML(buf, child)
+	}
+	buf.WriteString("</" + tag + ">")
+}
+
+// attributeValue returns the value of the attribute as it's valid in XHTML.
+func attributeValue(key, value string) (string, bool) {
+	switch {
+	case key == "href":
+		// links within the page may point to elements which were removed
+		u, err := url.Parse(value)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "mailto") {
+			return "", false
+		}
+		return u.String(), true
+	case key == "reversed":
+		return "reversed", true
+	case slices.Contains(numericAttributes, key):
+		if n, err := strconv.Atoi(strings.TrimSpace(value)); err != nil || n < 0 {
+			return "", false
+		}
+		return strings.TrimSpace(value), true
+	}
+	return value, true
+}
+
+// imageMediaTypes are the image types EPUB readers support.
+var imageMediaTypes = map[string]string{
+	"image/jpeg":    "jpg",
+	"image/png":     "png",
+	"image/gif":     "gif",
+	"image/webp":    "webp",
+	"image/svg+xml": "svg",
+}
+
+// image is an image embedded into the book, the ID and file are assigned once all images are fetched.
+type image struct {
+	ID        string
+	File      string
+	MediaType string
+	data      []byte
+}
+
+// imageFetchWorkers is the number of images fetched at the same time.
+const imageFetchWorkers = 4
+
+type imageFetcher struct {
+	log     *slog.Logger
+	client  *http.Client
+	maxSize int64
+}
+
+func newImageFetcher(log *slog.Logger, client *http.Client, maxSize int64) *imageFetcher {
+	return &imageFetcher{log: log, client: client, maxSize: maxSize}
+}
+
+// fetchAll fetches the images at the given URLs concurrently and returns the images which can be embedded
+// by their URL and in the order of the URLs.
+func (f *imageFetcher) fetchAll(ctx context.Context, srcs []string) (map[string]*image, []*image) {
+	fetched := make([]*image, len(srcs))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for range min(imageFetchWorkers, len(srcs)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				img, err := f.download(ctx, srcs[i])
+				if err != nil {
+					f.log.Warn("Unable to embed image, leaving it out", slog.String("url", srcs[i]), slog.String("error", err.Error()))
+					continue
+				}
+				fetched[i] = img
+			}
+		}()
+	}
+	for i := range srcs {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	bySrc := make(map[string]*image)
+	var images []*image
+	for i, img := range fetched {
+		if img == nil {
+			continue
+		}
+		n := len(images) + 1
+		img.ID = fmt.Sprintf("image-%04d", n)
+		img.File = fmt.Sprintf("images/%04d.%s", n, imageMediaTypes[img.MediaType])
+		bySrc[srcs[i]] = img
+		images = append(images, img)
+	}
+	return bySrc, images
+}
+
+func (f *imageFetcher) download(ctx context.Context, src string) (*image, error) {
+	u, err := url.Parse(src)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, fmt.Errorf("unsupported image URL")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, src, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, f.maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > f.maxSize {
+		return nil, fmt.Errorf("image is larger than %d bytes", f.maxSize)
+	}
+
+	// servers often send a generic content type, so it's sniffed instead, except for SVGs which can't be sniffed
+	mediaType := strings.Split(http.DetectContentType(data), ";")[0]
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "image/svg+xml") {
+		mediaType = "image/svg+xml"
+	}
+	if _, ok := imageMediaTypes[mediaType]; !ok {
+		return nil, fmt.Errorf("unsupported image type %s", mediaType)
+	}
+	return &image{MediaType: mediaType, data: data}, nil
+}
@@ -0,0 +1,211 @@
+// Package events streams the progress of clip requests to clients as Server-Sent Events.
+// Recent events are kept, so that clients reconnecting with the Last-Event-ID header get the events they missed.
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/timofurrer/influss/internal/store"
+)
+
+// Type is the kind of a clip request event.
+type Type string
+
+const (
+	// TypeQueued is sent when a URL is about to be clipped.
+	TypeQueued Type = "clip.queued"
+	// TypeSucceeded is sent when the clip of a queued URL was stored.
+	TypeSucceeded Type = "clip.succeeded"
+	// TypeFailed is sent when clipping a URL failed.
+	TypeFailed Type = "clip.failed"
+)
+
+const (
+	// keptEvents is the number of recent events kept for clients resuming the stream.
+	keptEvents = 1000
+	// pendingTimeout is how long a queued URL is waited for to be stored, e.g. when storing it failed.
+	pendingTimeout = 10 * time.Minute
+	// keepAliveInterval is the interval of comments sent to keep idle connections open through proxies.
+	keepAliveInterval = 30 * time.Second
+)
+
+type Event struct {
+	ID        uint64    `json:"id"`
+	Type      Type      `json:"type"`
+	URL       string    `json:"url"`
+	ClipID    int64     `json:"clip_id,omitempty"`
+	Title     string    `json:"title,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Broker keeps the recent events and wakes up the streams when new events are published.
+type Broker struct {
+	log *slog.Logger
+
+	mu     sync.Mutex
+	lastID uint64
+	// events are the recent events, oldest first.
+	events []Event
+	// pending are the queued URLs with the time they were queued.
+	pending map[string]time.Time
+	streams map[chan struct{}]struct{}
+}
+
+func New(log *slog.Logger) *Broker {
+	return &Broker{
+		log: log,
+		// the IDs start at the current time in milliseconds, so that they keep increasing across restarts
+		// and clients resuming with the ID of an event before the restart get all kept events.
+		lastID:  uint64(time.Now().UnixMilli()),
+		pending: make(map[string]time.Time),
+		streams: make(map[chan struct{}]struct{}),
+	}
+}
+
+// Register registers the event stream endpoint on the given mux.
+func (b *Broker) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /events", b.streamFunc)
+}
+
+// Queued publishes the clip.queued event, it's meant to be the clipper's hook called before clipping a URL.
+func (b *Broker) Queued(url string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.pending[url] = time.Now()
+	b.publish(Event{Type: TypeQueued, URL: url})
+}
+
+// Failed publishes the clip.failed event, it's meant to be the clipper's hook called when clipping a URL failed.
+func (b *Broker) Failed(url string, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.pending, url)
+	b.publish(Event{Type: TypeFailed, URL: url, Error: err.Error()})
+}
+
+// OnChange is a store.ChangeHook which publishes the clip.succeeded event when a queued URL was stored.
+func (b *Broker) OnChange(_ context.Context, ch store.Change) {
+	if ch.Type == store.ChangeDeleted {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.pending[ch.Clip.URL]; !ok {
+		return
+	}
+	delete(b.pending, ch.Clip.URL)
+	b.publish(Event{Type: TypeSucceeded, URL: ch.Clip.URL, ClipID: ch.Clip.ID, Title: ch.Clip.Title})
+}
+
+// publish keeps the event and wakes up the streams, the lock must be held.
+func (b *Broker) publish(e Event) {
+	b.lastID++
+	e.ID = b.lastID
+	e.CreatedAt = time.Now().UTC()
+	b.events = append(b.events, e)
+	if len(b.events) > keptEvents {
+		b.events = append([]Event(nil), b.events[len(b.events)-keptEvents:]...)
+	}
+
+	for url, queuedAt := range b.pending {
+		if time.Since(queuedAt) > pendingTimeout {
+			delete(b.pending, url)
+		}
+	}
+
+	for wake := range b.streams {
+		select {
+		case wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// since returns the kept events after the event with the given ID.
+func (b *Broker) since(id uint64) []Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var events []Event
+	for _, e := range b.events {
+		if e.ID > id {
+			events = append(events, e)
+		}
+	}
+	return events
+}
+
+// streamFunc streams the events until the client disconnects.
+// Without a Last-Event-ID header or last_event_id query parameter only new events are streamed.
+func (b *Broker) streamFunc(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	wake := make(chan struct{}, 1)
+	b.mu.Lock()
+	lastID := b.lastID
+	b.streams[wake] = struct{}{}
+	b.mu.Unlock()
+	defer func() {
+		b.mu.Lock()
+		delete(b.streams, wake)
+		b.mu.Unlock()
+	}()
+
+	resumeID := r.Header.Get("Last-Event-ID")
+	if resumeID == "" {
+		resumeID = r.URL.Query().Get("last_event_id")
+	}
+	if resumeID != "" {
+		id, err := strconv.ParseUint(resumeID, 10, 64)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Invalid last event ID %q", resumeID), http.StatusBadRequest)
+			return
+		}
+		lastID = id
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	// disables the response buffering of nginx
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(keepAliveInterval)
+	defer keepAlive.Stop()
+	for {
+		for _, e := range b.since(lastID) {
+			data, err := json.Marshal(e)
+			if err != nil {
+				b.log.Error("Failed to encode event", slog.String("error", err.Error()))
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, data); err != nil {
+				return
+			}
+			lastID = e.ID
+		}
+		flusher.Flush()
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-wake:
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -2,12 +2,25 @@ package feed
 
 import (
 	"fmt"
+	"mime"
+	"net/url"
+	"path"
+	"strings"
 	"time"
 
 	"github.com/gorilla/feeds"
 	"github.com/timofurrer/influss/internal/clip"
 )
 
+// ReadingTimePlacement defines where the estimated reading time of a clip is shown in a feed item.
+type ReadingTimePlacement string
+
+const (
+	ReadingTimeNone        ReadingTimePlacement = "none"
+	ReadingTimeTitle       ReadingTimePlacement = "title"
+	ReadingTimeDescription ReadingTimePlacement = "description"
+)
+
 type Config struct {
 	Title       string
 	Link        string
@@ -16,11 +29,19 @@ type Config struct {
 	AuthorEmail string
 	Category    string
 	CreatedAt   time.Time
+	ReadingTime ReadingTimePlacement
+	// SelfURL is the URL of the feed itself, which WebSub subscribers subscribe to.
+	SelfURL string
+	// HubURL is the URL of the WebSub hub which pushes the feed to subscribers.
+	HubURL string
 }
 
 type Builder struct {
-	feed    *feeds.RssFeed
-	pubDate time.Time
+	feed        *feeds.RssFeed
+	items       []*rssItem
+	pubDate     time.Time
+	readingTime ReadingTimePlacement
+	links       []*atomLink
 }
 
 func NewBuidler(cfg Config) *Builder {
@@ -33,28 +54,61 @@ func NewBuidler(cfg Config) *Builder {
 		Category:       cfg.Category,
 		Copyright:      fmt.Sprintf("influss and %s", cfg.AuthorName),
 	}
-	return &Builder{
-		feed:    f,
-		pubDate: cfg.CreatedAt,
+	b := &Builder{
+		feed:        f,
+		pubDate:     cfg.CreatedAt,
+		readingTime: cfg.ReadingTime,
+	}
+	if cfg.SelfURL != "" {
+		b.links = append(b.links, &atomLink{Rel: "self", Href: cfg.SelfURL, Type: "application/rss+xml"})
 	}
+	if cfg.HubURL != "" {
+		b.links = append(b.links, &atomLink{Rel: "hub", Href: cfg.HubURL})
+	}
+	return b
 }
 
 func (f *Builder) WithClip(c *clip.Clip) {
-	f.feed.Items = append(f.feed.Items, &feeds.RssItem{
-		Guid: &feeds.RssGuid{
-			Id:          c.URL,
-			IsPermaLink: "true",
+	title, description := c.Title, c.Excerpt
+	if c.ReadingTimeMinutes > 0 {
+		prefix := fmt.Sprintf("[%d min]", c.ReadingTimeMinutes)
+		switch f.readingTime {
+		case ReadingTimeTitle:
+			title = fmt.Sprintf("%s %s", prefix, title)
+		case ReadingTimeDescription:
+			description = fmt.Sprintf("%s %s", prefix, description)
+		}
+	}
+
+	item := &rssItem{
+		RssItem: &feeds.RssItem{
+			Guid: &feeds.RssGuid{
+				Id:          c.URL,
+				IsPermaLink: "true",
+			},
+			Title:       title,
+			Link:        c.URL,
+			Author:      c.Author,
+			Description: description,
+			PubDate:     c.ModifiedAt.Format(time.RFC1123Z),
+			Content: &feeds.RssContent{
+				Content: c.HTMLContent,
+			},
 		},
-		Title:       c.Title,
-		Link:        c.URL,
-		Source:      c.URL,
-		Author:      c.Author,
-		Description: c.Excerpt,
-		PubDate:     c.ModifiedAt.Format(time.RFC1123Z),
-		Content: &feeds.RssContent{
-			Content: c.HTMLContent,
+		Source: &rssSource{
+			URL:  c.URL,
+			Name: c.SiteName,
 		},
-	})
+	}
+	if c.Image != "" {
+		item.Enclosure = &feeds.RssEnclosure{
+			Url:    c.Image,
+			Length: "0",
+			Type:   imageType(c.Image),
+		}
+		item.Thumbnail = &mediaThumbnail{URL: c.Image}
+	}
+	f.items = append(f.items, item)
 	if c.ModifiedAt.After(f.pubDate) {
 		f.pubDate = c.ModifiedAt
 	}
@@ -62,10 +116,30 @@ func (f *Builder) WithClip(c *clip.Clip) {
 
 func (f *Builder) ToXML() ([]byte, error) {
 	f.feed.PubDate = f.pubDate.Format(time.RFC1123Z)
-	data, err := feeds.ToXML(f.feed)
+	data, err := feeds.ToXML(&rssFeedXML{
+		Version:          "2.0",
+		ContentNamespace: "http://purl.org/rss/1.0/modules/content/",
+		MediaNamespace:   mediaNamespace,
+		AtomNamespace:    atomNamespace,
+		Channel: &rssChannel{
+			RssFeed: f.feed,
+			Links:   f.links,
+			Items:   f.items,
+		},
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate feed XML: %w", err)
 	}
 
 	return []byte(data), nil
 }
+
+// imageType guesses the MIME type of the image at the given URL from its file extension.
+func imageType(imageURL string) string {
+	if u, err := url.Parse(imageURL); err == nil {
+		if t := mime.TypeByExtension(path.Ext(u.Path)); strings.HasPrefix(t, "image/") {
+			return t
+		}
+	}
+	return "image/jpeg"
+}
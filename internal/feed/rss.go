@@ -0,0 +1,59 @@
+package feed
+
+import (
+	"encoding/xml"
+
+	"github.com/gorilla/feeds"
+)
+
+const (
+	mediaNamespace = "http://search.yahoo.com/mrss/"
+	atomNamespace  = "http://www.w3.org/2005/Atom"
+)
+
+// rssFeedXML is the <rss> root element. In contrast to the one from gorilla/feeds
+// it declares the additional namespaces used by the items.
+type rssFeedXML struct {
+	XMLName          xml.Name `xml:"rss"`
+	Version          string   `xml:"version,attr"`
+	ContentNamespace string   `xml:"xmlns:content,attr"`
+	MediaNamespace   string   `xml:"xmlns:media,attr"`
+	AtomNamespace    string   `xml:"xmlns:atom,attr"`
+	Channel          *rssChannel
+}
+
+func (f *rssFeedXML) FeedXml() interface{} {
+	return f
+}
+
+// rssChannel extends the gorilla/feeds channel with Atom links and extended items.
+type rssChannel struct {
+	*feeds.RssFeed
+	Links []*atomLink
+	Items []*rssItem `xml:"item"`
+}
+
+// atomLink links the feed to itself and to its WebSub hub.
+type atomLink struct {
+	XMLName xml.Name `xml:"atom:link"`
+	Rel     string   `xml:"rel,attr"`
+	Href    string   `xml:"href,attr"`
+	Type    string   `xml:"type,attr,omitempty"`
+}
+
+// rssItem extends the gorilla/feeds item with elements which are not supported by it.
+type rssItem struct {
+	*feeds.RssItem
+	Source    *rssSource `xml:"source,omitempty"`
+	Thumbnail *mediaThumbnail
+}
+
+type rssSource struct {
+	URL  string `xml:"url,attr"`
+	Name string `xml:",chardata"`
+}
+
+type mediaThumbnail struct {
+	XMLName xml.Name `xml:"media:thumbnail"`
+	URL     string   `xml:"url,attr"`
+}
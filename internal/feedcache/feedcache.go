@@ -0,0 +1,180 @@
+// Package feedcache caches rendered feeds per feed variant until the clips change,
+// optionally with precompressed bodies which are served with the matching Content-Encoding.
+package feedcache
+
+import (
+	"bytes"
+	"cmp"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andybalholm/brotli"
+	"github.com/timofurrer/influss/internal/store"
+)
+
+type Config struct {
+	// Compress stores gzip and brotli compressed bodies besides the uncompressed one.
+	Compress bool
+	// MaxEntries is the maximum number of cached feed variants, the oldest one is evicted when it's exceeded.
+	MaxEntries int
+}
+
+type Cache struct {
+	cfg Config
+
+	mu      sync.Mutex
+	entries map[string]*Entry
+	// generation is incremented on every invalidation, so that feeds rendered before it aren't cached.
+	generation uint64
+}
+
+func New(cfg Config) *Cache {
+	cfg.MaxEntries = cmp.Or(cfg.MaxEntries, 100)
+	return &Cache{cfg: cfg, entries: make(map[string]*Entry)}
+}
+
+// Entry is a cached feed.
+type Entry struct {
+	body     []byte
+	gzip     []byte
+	brotli   []byte
+	etag     string
+	storedAt time.Time
+}
+
+// Get returns the cached feed of the variant.
+// If it's not cached, it returns the generation to pass to Put after rendering the feed.
+func (c *Cache) Get(key string) (*Entry, uint64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	return e, c.generation, ok
+}
+
+// Put caches the rendered feed of the variant, unless the cache was invalidated since the given generation.
+// It returns the entry to serve in any case.
+func (c *Cache) Put(key string, generation uint64, body []byte) *Entry {
+	sum := sha256.Sum256(body)
+	e := &Entry{body: body, etag: `"` + hex.EncodeToString(sum[:16]) + `"`, storedAt: time.Now()}
+	if c.cfg.Compress {
+		e.gzip = compressGzip(body)
+		e.brotli = compressBrotli(body)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if generation != c.generation {
+		return e
+	}
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= c.cfg.MaxEntries {
+		c.evictOldest()
+	}
+	c.entries[key] = e
+	return e
+}
+
+// Invalidate drops all cached feeds.
+func (c *Cache) Invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[string]*Entry)
+	c.generation++
+}
+
+// Run invalidates the cache on the store events until the context is canceled or the events are closed.
+func (c *Cache) Run(ctx context.Context, events <-chan store.Event) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case _, ok := <-events:
+			if !ok {
+				return
+			}
+			c.Invalidate()
+		}
+	}
+}
+
+func (c *Cache) evictOldest() {
+	var oldestKey string
+	var oldest *Entry
+	for k, e := range c.entries {
+		if oldest == nil || e.storedAt.Before(oldest.storedAt) {
+			oldestKey, oldest = k, e
+		}
+	}
+	delete(c.entries, oldestKey)
+}
+
+// Serve writes the feed with the given content type, compressed if the client accepts it.
+// It responds with 304 Not Modified if the client already has the feed.
+func (e *Entry) Serve(w http.ResponseWriter, r *http.Request, contentType string) {
+	w.Header().Set("ETag", e.etag)
+	w.Header().Set("Vary", "Accept-Encoding")
+	if match := r.Header.Get("If-None-Match"); match != "" && strings.Contains(match, e.etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	body := e.body
+	accepted := acceptedEncodings(r.Header.Get("Accept-Encoding"))
+	switch {
+	case e.brotli != nil && accepted["br"]:
+		body = e.brotli
+		w.Header().Set("Content-Encoding", "br")
+	case e.gzip != nil && accepted["gzip"]:
+		body = e.gzip
+		w.Header().Set("Content-Encoding", "gzip")
+	}
+
+	if contentType != "" {
+		w.Header().Set("Content-Type", contentType)
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(http.StatusOK)
+	if r.Method != http.MethodHead {
+		w.Write(body)
+	}
+}
+
+// acceptedEncodings parses the Accept-Encoding header, encodings with a quality of 0 aren't accepted.
+func acceptedEncodings(header string) map[string]bool {
+	accepted := make(map[string]bool)
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+				q = parsed
+			}
+		}
+		if name != "" && q > 0 {
+			accepted[strings.ToLower(name)] = true
+		}
+	}
+	return accepted
+}
+
+func compressGzip(data []byte) []byte {
+	var buf bytes.Buffer
+	zw, _ := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	zw.Write(data)
+	zw.Close()
+	return buf.Bytes()
+}
+
+func compressBrotli(data []byte) []byte {
+	var buf bytes.Buffer
+	bw := brotli.NewWriterLevel(&buf, brotli.DefaultCompression)
+	bw.Write(data)
+	bw.Close()
+	return buf.Bytes()
+}
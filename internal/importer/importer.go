@@ -0,0 +1,136 @@
+package importer
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/timofurrer/influss/internal/clip"
+	"github.com/timofurrer/influss/internal/store"
+)
+
+// Format is the format of an export file of another read-it-later service.
+type Format string
+
+const (
+	FormatPocket            Format = "pocket"
+	FormatInstapaper        Format = "instapaper"
+	FormatWallabag          Format = "wallabag"
+	FormatOmnivore          Format = "omnivore"
+	FormatNetscapeBookmarks Format = "netscape-bookmarks"
+)
+
+// Formats are all supported export formats.
+var Formats = []Format{FormatPocket, FormatInstapaper, FormatWallabag, FormatOmnivore, FormatNetscapeBookmarks}
+
+// Entry is a saved link from an export file.
+type Entry struct {
+	URL     string
+	Title   string
+	Author  string
+	SavedAt time.Time
+	Tags    []string
+	Read    bool
+	Starred bool
+	// Content is the already extracted article HTML content, if the export contains it.
+	Content string
+}
+
+// Parse parses the export file in the given format.
+// The dir is the directory of the export file, which is used to find content files referenced by the export.
+func Parse(format Format, r io.Reader, dir string) ([]Entry, error) {
+	switch format {
+	case FormatPocket:
+		return parsePocket(r)
+	case FormatInstapaper:
+		return parseInstapaper(r)
+	case FormatWallabag:
+		return parseWallabag(r)
+	case FormatOmnivore:
+		return parseOmnivore(r, dir)
+	case FormatNetscapeBookmarks:
+		return parseNetscapeBookmarks(r)
+	default:
+		return nil, fmt.Errorf("unsupported import format %q", format)
+	}
+}
+
+// Importer clips the entries of an export file and stores them.
+type Importer struct {
+	log     *slog.Logger
+	clipper *clip.Clipper
+	store   store.Store
+	// rateLimit is the minimum duration between fetching two entries.
+	rateLimit time.Duration
+}
+
+func New(log *slog.Logger, clipper *clip.Clipper, store store.Store, rateLimit time.Duration) *Importer {
+	return &Importer{log: log, clipper: clipper, store: store, rateLimit: rateLimit}
+}
+
+// Import clips and stores the given entries.
+// Entries which fail to be clipped are stored with their URL and title only, so that no link gets lost.
+// It returns the number of stored entries.
+func (i *Importer) Import(ctx context.Context, entries []Entry) (int, error) {
+	var lastFetch time.Time
+	imported := 0
+	for n, e := range entries {
+		log := i.log.With(slog.String("url", e.URL), slog.Int("entry", n+1), slog.Int("entries", len(entries)))
+
+		var c *clip.Clip
+		var err error
+		if e.Content != "" {
+			c, err = clip.FromContent(e.URL, e.Title, e.Content)
+		} else {
+			if wait := i.rateLimit - time.Since(lastFetch); wait > 0 {
+				select {
+				case <-ctx.Done():
+					return imported, ctx.Err()
+				case <-time.After(wait):
+				}
+			}
+			lastFetch = time.Now()
+			c, err = i.clipper.ClipURL(e.URL)
+		}
+		if err != nil {
+			log.Warn("Unable to clip entry, storing link only", slog.String("error", err.Error()))
+			c = &clip.Clip{URL: e.URL, PublishedAt: e.SavedAt, ModifiedAt: e.SavedAt}
+		}
+
+		c.Title = cmp.Or(c.Title, e.Title, e.URL)
+		c.Author = cmp.Or(c.Author, e.Author)
+		c.ClippedAt = e.SavedAt
+		c.Tags = normalizeTags(e.Tags)
+		c.Read = e.Read
+		c.Starred = e.Starred
+
+		if err := i.store.Store(ctx, c); err != nil {
+			return imported, fmt.Errorf("failed to store entry %s: %w", e.URL, err)
+		}
+		imported++
+		log.Info("Imported entry")
+	}
+	return imported, nil
+}
+
+func normalizeTags(tags []string) []string {
+	normalized := make([]string, 0, len(tags))
+	for _, t := range tags {
+		if t = strings.TrimSpace(t); t != "" && !slices.Contains(normalized, t) {
+			normalized = append(normalized, t)
+		}
+	}
+	return normalized
+}
+
+// splitTags splits a list of tags separated by any of the given separators.
+func splitTags(s string, separators string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return strings.ContainsRune(separators, r)
+	})
+}
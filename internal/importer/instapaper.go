@@ -0,0 +1,49 @@
+package importer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// parseInstapaper parses an Instapaper CSV export with the columns
+// URL, Title, Selection, Folder, Timestamp and optionally Tags.
+// Entries in the Archive folder are considered read, other folders are used as tags.
+func parseInstapaper(r io.Reader) ([]Entry, error) {
+	records, err := readCSV(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse Instapaper CSV export: %w", err)
+	}
+
+	entries := make([]Entry, 0, len(records))
+	for _, rec := range records {
+		e := Entry{
+			URL:     rec["url"],
+			Title:   rec["title"],
+			SavedAt: parseUnixTime(rec["timestamp"]),
+		}
+
+		switch folder := rec["folder"]; strings.ToLower(folder) {
+		case "archive":
+			e.Read = true
+		case "starred":
+			e.Starred = true
+		case "unread", "":
+		default:
+			e.Tags = append(e.Tags, folder)
+		}
+
+		// newer exports contain the tags as JSON array
+		if tags := rec["tags"]; tags != "" {
+			var parsed []string
+			if err := json.Unmarshal([]byte(tags), &parsed); err != nil {
+				parsed = splitTags(tags, ",")
+			}
+			e.Tags = append(e.Tags, parsed...)
+		}
+
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
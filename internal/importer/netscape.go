@@ -0,0 +1,35 @@
+package importer
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/go-shiori/dom"
+	"golang.org/x/net/html"
+)
+
+// parseNetscapeBookmarks parses a bookmarks file in the Netscape bookmark format,
+// as exported by browsers and many bookmarking services.
+// The format has no read state, so all bookmarks are imported as unread.
+func parseNetscapeBookmarks(r io.Reader) ([]Entry, error) {
+	doc, err := html.Parse(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse bookmarks file: %w", err)
+	}
+
+	var entries []Entry
+	for _, n := range dom.QuerySelectorAll(doc, "a[href]") {
+		href := dom.GetAttribute(n, "href")
+		if !strings.HasPrefix(href, "http://") && !strings.HasPrefix(href, "https://") {
+			continue
+		}
+		entries = append(entries, Entry{
+			URL:     href,
+			Title:   strings.TrimSpace(dom.TextContent(n)),
+			SavedAt: parseUnixTime(dom.GetAttribute(n, "add_date")),
+			Tags:    splitTags(dom.GetAttribute(n, "tags"), ","),
+		})
+	}
+	return entries, nil
+}
@@ -0,0 +1,79 @@
+package importer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type omnivoreEntry struct {
+	Slug    string          `json:"slug"`
+	URL     string          `json:"url"`
+	Title   string          `json:"title"`
+	Author  string          `json:"author"`
+	State   string          `json:"state"`
+	Labels  []omnivoreLabel `json:"labels"`
+	SavedAt string          `json:"savedAt"`
+	Content string          `json:"content"`
+}
+
+// omnivoreLabel is either a plain string or an object with a name, depending on the export version.
+type omnivoreLabel string
+
+func (l *omnivoreLabel) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		*l = omnivoreLabel(name)
+		return nil
+	}
+	var label struct {
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal(data, &label); err != nil {
+		return err
+	}
+	*l = omnivoreLabel(label.Name)
+	return nil
+}
+
+// parseOmnivore parses an Omnivore `metadata_*.json` export file.
+// The article content is read from the `content/<slug>.html` files next to it, if present.
+func parseOmnivore(r io.Reader, dir string) ([]Entry, error) {
+	var exported []omnivoreEntry
+	if err := json.NewDecoder(r).Decode(&exported); err != nil {
+		return nil, fmt.Errorf("failed to parse Omnivore JSON export: %w", err)
+	}
+
+	entries := make([]Entry, 0, len(exported))
+	for _, e := range exported {
+		if e.URL == "" {
+			continue
+		}
+
+		tags := make([]string, 0, len(e.Labels))
+		for _, l := range e.Labels {
+			tags = append(tags, string(l))
+		}
+
+		content := e.Content
+		if content == "" && e.Slug != "" && dir != "" {
+			if data, err := os.ReadFile(filepath.Join(dir, "content", e.Slug+".html")); err == nil {
+				content = string(data)
+			}
+		}
+
+		entries = append(entries, Entry{
+			URL:     e.URL,
+			Title:   e.Title,
+			Author:  e.Author,
+			SavedAt: parseTime(e.SavedAt),
+			Tags:    tags,
+			Read:    strings.EqualFold(e.State, "archived"),
+			Content: content,
+		})
+	}
+	return entries, nil
+}
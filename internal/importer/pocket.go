@@ -0,0 +1,120 @@
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-shiori/dom"
+	"golang.org/x/net/html"
+)
+
+// parsePocket parses a Pocket export, either the legacy `ril_export.html`
+// or the CSV export with the columns title, url, time_added, tags and status.
+func parsePocket(r io.Reader) ([]Entry, error) {
+	br := bufio.NewReader(r)
+	if isHTML(br) {
+		return parsePocketHTML(br)
+	}
+	return parsePocketCSV(br)
+}
+
+func parsePocketHTML(r io.Reader) ([]Entry, error) {
+	doc, err := html.Parse(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse Pocket HTML export: %w", err)
+	}
+
+	// the export has an "Unread" and a "Read Archive" section, each a heading followed by a list
+	var entries []Entry
+	read := false
+	for _, n := range dom.QuerySelectorAll(doc, "h1, a[href]") {
+		if dom.TagName(n) == "h1" {
+			read = strings.Contains(strings.ToLower(dom.TextContent(n)), "archive")
+			continue
+		}
+		entries = append(entries, Entry{
+			URL:     dom.GetAttribute(n, "href"),
+			Title:   strings.TrimSpace(dom.TextContent(n)),
+			SavedAt: parseUnixTime(dom.GetAttribute(n, "time_added")),
+			Tags:    splitTags(dom.GetAttribute(n, "tags"), ","),
+			Read:    read,
+		})
+	}
+	return entries, nil
+}
+
+func parsePocketCSV(r io.Reader) ([]Entry, error) {
+	records, err := readCSV(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse Pocket CSV export: %w", err)
+	}
+
+	entries := make([]Entry, 0, len(records))
+	for _, rec := range records {
+		entries = append(entries, Entry{
+			URL:     rec["url"],
+			Title:   rec["title"],
+			SavedAt: parseUnixTime(rec["time_added"]),
+			Tags:    splitTags(rec["tags"], "|,"),
+			Read:    rec["status"] == "archive",
+		})
+	}
+	return entries, nil
+}
+
+// isHTML checks if the buffered input starts with an HTML tag.
+func isHTML(br *bufio.Reader) bool {
+	start, _ := br.Peek(512)
+	return bytes.HasPrefix(bytes.TrimSpace(bytes.TrimPrefix(start, []byte("\xef\xbb\xbf"))), []byte("<"))
+}
+
+// readCSV reads a CSV file with a header row into records keyed by the lower case column names.
+// Records without a URL are skipped.
+func readCSV(r io.Reader) ([]map[string]string, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, err
+	}
+	for i, h := range header {
+		header[i] = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(h, "\xef\xbb\xbf")))
+	}
+
+	var records []map[string]string
+	for {
+		row, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		rec := make(map[string]string, len(header))
+		for i, v := range row {
+			if i < len(header) {
+				rec[header[i]] = strings.TrimSpace(v)
+			}
+		}
+		if rec["url"] != "" {
+			records = append(records, rec)
+		}
+	}
+	return records, nil
+}
+
+func parseUnixTime(s string) time.Time {
+	sec, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+	if err != nil || sec <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(sec, 0)
+}
@@ -0,0 +1,56 @@
+package importer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+type wallabagEntry struct {
+	URL         string   `json:"url"`
+	Title       string   `json:"title"`
+	Content     string   `json:"content"`
+	IsArchived  int      `json:"is_archived"`
+	IsStarred   int      `json:"is_starred"`
+	Tags        []string `json:"tags"`
+	PublishedBy []string `json:"published_by"`
+	CreatedAt   string   `json:"created_at"`
+}
+
+// parseWallabag parses a Wallabag JSON export which includes the article content.
+func parseWallabag(r io.Reader) ([]Entry, error) {
+	var exported []wallabagEntry
+	if err := json.NewDecoder(r).Decode(&exported); err != nil {
+		return nil, fmt.Errorf("failed to parse Wallabag JSON export: %w", err)
+	}
+
+	entries := make([]Entry, 0, len(exported))
+	for _, e := range exported {
+		if e.URL == "" {
+			continue
+		}
+		entries = append(entries, Entry{
+			URL:     e.URL,
+			Title:   e.Title,
+			Author:  strings.Join(e.PublishedBy, ", "),
+			SavedAt: parseTime(e.CreatedAt),
+			Tags:    e.Tags,
+			Read:    e.IsArchived != 0,
+			Starred: e.IsStarred != 0,
+			Content: e.Content,
+		})
+	}
+	return entries, nil
+}
+
+// parseTime parses the timestamps used by the JSON exports, it returns the zero time if it fails.
+func parseTime(s string) time.Time {
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05-0700", "2006-01-02 15:04:05"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,242 @@
+// Package inbox receives emails via SMTP or LMTP and stores them as clips,
+// either by clipping the links in the email or by storing a newsletter itself.
+package inbox
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"log/slog"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-shiori/dom"
+	"github.com/timofurrer/influss/internal/api"
+	"github.com/timofurrer/influss/internal/clip"
+	"github.com/timofurrer/influss/internal/store"
+)
+
+// Mode is how a received email is turned into clips.
+type Mode string
+
+const (
+	// ModeAuto clips the links of short emails and stores longer emails as newsletter.
+	ModeAuto Mode = "auto"
+	// ModeLinks clips every link in the email.
+	ModeLinks Mode = "links"
+	// ModeNewsletter stores the email itself as clip.
+	ModeNewsletter Mode = "newsletter"
+)
+
+var Modes = []Mode{ModeAuto, ModeLinks, ModeNewsletter}
+
+const (
+	// maxLinkMailWords is the maximum number of words besides the links of an email which ModeAuto clips the links of.
+	maxLinkMailWords = 50
+	// maxLinks is the maximum number of links clipped from a single email.
+	maxLinks = 20
+)
+
+type Config struct {
+	Addr     string
+	Protocol Protocol
+	Mode     Mode
+	// Recipients are the addresses mail is accepted for.
+	Recipients []string
+	// AllowedSenders are the addresses or domains, like `@example.com`, which may send mail.
+	// They are checked against the From header of the email.
+	AllowedSenders []string
+	// MaxMessageSize is the maximum size of an email in bytes.
+	MaxMessageSize int64
+}
+
+type Server struct {
+	log      *slog.Logger
+	cfg      Config
+	clipper  *clip.Clipper
+	store    store.Store
+	hostname string
+}
+
+func New(log *slog.Logger, cfg Config, clipper *clip.Clipper, store store.Store) *Server {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "influss"
+	}
+	cfg.Protocol = cmp.Or(cfg.Protocol, ProtocolSMTP)
+	cfg.Mode = cmp.Or(cfg.Mode, ModeAuto)
+	cfg.MaxMessageSize = cmp.Or(cfg.MaxMessageSize, 10<<20)
+	return &Server{log: log, cfg: cfg, clipper: clipper, store: store, hostname: hostname}
+}
+
+func (s *Server) acceptsRecipient(addr string) bool {
+	return slices.ContainsFunc(s.cfg.Recipients, func(r string) bool {
+		return strings.EqualFold(r, addr)
+	})
+}
+
+func (s *Server) allowedSender(addr string) bool {
+	addr = strings.ToLower(addr)
+	_, domain, _ := strings.Cut(addr, "@")
+	return slices.ContainsFunc(s.cfg.AllowedSenders, func(allowed string) bool {
+		allowed = strings.ToLower(allowed)
+		if strings.HasPrefix(allowed, "@") {
+			return domain == allowed[1:]
+		}
+		return addr == allowed
+	})
+}
+
+// deliver stores the received email as clips.
+// It returns a rejectError if the email is refused.
+func (s *Server) deliver(ctx context.Context, data []byte) error {
+	msg, err := parseMessage(data)
+	if err != nil {
+		return reject("Invalid message: %s", err)
+	}
+	if msg.from == nil || !s.allowedSender(msg.from.Address) {
+		return reject("Sender is not allowed")
+	}
+	log := s.log.With(slog.String("message_id", msg.id), slog.String("from", msg.from.Address))
+
+	// a forwarded attachment is the newsletter, while the links may be in the forwarding email
+	content := msg
+	if msg.forwarded != nil {
+		content = msg.forwarded
+	}
+	links := msg.links()
+	if len(links) == 0 && msg.forwarded != nil {
+		links = msg.forwarded.links()
+	}
+
+	mode := s.cfg.Mode
+	if mode == ModeAuto {
+		mode = ModeNewsletter
+		if len(links) > 0 && msg.forwarded == nil && msg.wordsBesidesLinks() <= maxLinkMailWords {
+			mode = ModeLinks
+		}
+	}
+
+	switch mode {
+	case ModeLinks:
+		if len(links) == 0 {
+			return reject("No links found in message")
+		}
+		return s.clipLinks(ctx, log, msg, links)
+	default:
+		return s.storeNewsletter(ctx, log, content)
+	}
+}
+
+// clipLinks clips and stores the links, links which fail to be clipped are stored with the URL only.
+func (s *Server) clipLinks(ctx context.Context, log *slog.Logger, msg *message, links []string) error {
+	if len(links) > maxLinks {
+		log.Warn("Message has too many links, clipping only the first ones", slog.Int("links", len(links)), slog.Int("max_links", maxLinks))
+		links = links[:maxLinks]
+	}
+
+	// the subject only describes the link if there is just one
+	var title string
+	if len(links) == 1 {
+		title = msg.title()
+	}
+
+	for _, u := range links {
+		log := log.With(slog.String("url", u))
+		if _, err := api.ClipAndStore(ctx, s.clipper, s.store, u, title); err != nil {
+			log.Warn("Unable to clip link, storing link only", slog.String("error", err.Error()))
+			if err := s.storeLink(ctx, u, title, msg.date); err != nil {
+				return err
+			}
+			continue
+		}
+		log.Info("Clipped link from mail")
+	}
+	return nil
+}
+
+// storeLink stores a clip with just the URL, unless the URL was already clipped.
+func (s *Server) storeLink(ctx context.Context, u, title string, date time.Time) error {
+	_, err := s.store.Get(ctx, u)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, store.ErrNotFound) {
+		return fmt.Errorf("failed to load existing clip: %w", err)
+	}
+
+	date = cmp.Or(date, time.Now())
+	c := &clip.Clip{URL: u, Title: cmp.Or(title, u), PublishedAt: date, ModifiedAt: date}
+	if err := s.store.Store(ctx, c); err != nil {
+		return fmt.Errorf("failed to store clip: %w", err)
+	}
+	return nil
+}
+
+// storeNewsletter stores the email itself as clip with a `mid:` URL of its message id.
+func (s *Server) storeNewsletter(ctx context.Context, log *slog.Logger, msg *message) error {
+	content := msg.html
+	if content == "" {
+		content = textToHTML(msg.text)
+	} else {
+		content = removeInlineImages(content)
+	}
+
+	u := "mid:" + url.PathEscape(msg.id)
+	c, err := clip.FromContent(u, cmp.Or(msg.title(), "Untitled newsletter"), content)
+	if err != nil {
+		return reject("Unable to read message content: %s", err)
+	}
+	c.Author = msg.author()
+	c.SiteName = msg.author()
+	if !msg.date.IsZero() {
+		c.PublishedAt = msg.date
+		c.ModifiedAt = msg.date
+	}
+
+	existing, err := s.store.Get(ctx, u)
+	switch {
+	case err == nil:
+		c.Tags = existing.Tags
+		c.Read = existing.Read
+		c.Starred = existing.Starred
+	case !errors.Is(err, store.ErrNotFound):
+		return fmt.Errorf("failed to load existing clip: %w", err)
+	}
+
+	if err := s.store.Store(ctx, c); err != nil {
+		return fmt.Errorf("failed to store clip: %w", err)
+	}
+	log.Info("Stored newsletter from mail", slog.String("title", c.Title))
+	return nil
+}
+
+// textToHTML converts a plain text email to HTML paragraphs.
+func textToHTML(text string) string {
+	var b strings.Builder
+	for _, p := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n\n") {
+		if p = strings.TrimSpace(p); p != "" {
+			b.WriteString("<p>" + strings.ReplaceAll(html.EscapeString(p), "\n", "<br>") + "</p>\n")
+		}
+	}
+	return b.String()
+}
+
+// removeInlineImages removes the images referencing attachments of the email, which aren't stored.
+func removeInlineImages(content string) string {
+	doc, err := dom.FastParse(strings.NewReader(content))
+	if err != nil {
+		return content
+	}
+	for _, img := range dom.GetElementsByTagName(doc, "img") {
+		if strings.HasPrefix(strings.ToLower(dom.GetAttribute(img, "src")), "cid:") && img.Parent != nil {
+			img.Parent.RemoveChild(img)
+		}
+	}
+	return dom.OuterHTML(doc)
+}
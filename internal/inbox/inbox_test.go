@@ -0,0 +1,43 @@
+package inbox
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/timofurrer/influss/internal/store"
+)
+
+func TestDeliverSanitizesNewsletter(t *testing.T) {
+	s := store.NewMemoryStore()
+	srv := New(slog.New(slog.NewTextHandler(io.Discard, nil)), Config{Mode: ModeNewsletter, AllowedSenders: []string{"@example.com"}}, nil, s)
+
+	msg := strings.ReplaceAll(`From: Newsletter <news@example.com>
+To: inbox@example.org
+Subject: Weekly news
+Message-ID: <weekly@example.com>
+Content-Type: text/html; charset=utf-8
+
+<html><body><h1 onmouseover="steal()">Weekly news</h1><script>steal()</script>
+<iframe src="https://tracker.example.com"></iframe><p>Read <a href="javascript:steal()">more</a></p></body></html>
+`, "\n", "\r\n")
+	if err := srv.deliver(context.Background(), []byte(msg)); err != nil {
+		t.Fatalf("failed to deliver message: %v", err)
+	}
+
+	c, err := s.Get(context.Background(), "mid:"+url.PathEscape("weekly@example.com"))
+	if err != nil {
+		t.Fatalf("failed to get newsletter: %v", err)
+	}
+	for _, unsafe := range []string{"steal", "iframe", "tracker"} {
+		if strings.Contains(c.HTMLContent, unsafe) {
+			t.Errorf("expected newsletter without %q, got %q", unsafe, c.HTMLContent)
+		}
+	}
+	if !strings.Contains(c.HTMLContent, "<p>Read <a>more</a></p>") {
+		t.Errorf("expected newsletter content to be kept, got %q", c.HTMLContent)
+	}
+}
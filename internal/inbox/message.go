@@ -0,0 +1,228 @@
+package inbox
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/go-shiori/dom"
+	"golang.org/x/net/html/charset"
+)
+
+// message is the relevant content of a received email.
+type message struct {
+	id      string
+	subject string
+	from    *mail.Address
+	date    time.Time
+	text    string
+	html    string
+	// forwarded is the message which was forwarded as attachment, if any.
+	forwarded *message
+}
+
+var wordDecoder = &mime.WordDecoder{CharsetReader: charset.NewReaderLabel}
+
+func parseMessage(data []byte) (*message, error) {
+	m, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	msg := &message{
+		id:      strings.Trim(strings.TrimSpace(m.Header.Get("Message-Id")), "<>"),
+		subject: strings.TrimSpace(m.Header.Get("Subject")),
+	}
+	if subject, err := wordDecoder.DecodeHeader(msg.subject); err == nil {
+		msg.subject = subject
+	}
+	if msg.id == "" {
+		// the content identifies the message, so that a redelivery doesn't create another clip
+		msg.id = fmt.Sprintf("%x@influss", sha256.Sum256(data))
+	}
+	parser := mail.AddressParser{WordDecoder: wordDecoder}
+	if from, err := parser.Parse(m.Header.Get("From")); err == nil {
+		msg.from = from
+	}
+	if date, err := m.Header.Date(); err == nil {
+		msg.date = date
+	}
+
+	if err := msg.readPart(m.Header.Get("Content-Type"), m.Header.Get("Content-Transfer-Encoding"), "", m.Body); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// readPart reads the text and HTML bodies from the part, descending into multipart parts.
+// The first text and HTML body which isn't an attachment is used.
+func (msg *message) readPart(contentType, encoding, disposition string, body io.Reader) error {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType, params = "text/plain", nil
+	}
+	if d, _, _ := mime.ParseMediaType(disposition); d == "attachment" && mediaType != "message/rfc822" {
+		return nil
+	}
+
+	switch {
+	case strings.HasPrefix(mediaType, "multipart/"):
+		mr := multipart.NewReader(body, params["boundary"])
+		for {
+			p, err := mr.NextPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			// the multipart reader already decodes quoted-printable parts and removes the header
+			if err := msg.readPart(p.Header.Get("Content-Type"), p.Header.Get("Content-Transfer-Encoding"), p.Header.Get("Content-Disposition"), p); err != nil {
+				return err
+			}
+		}
+	case mediaType == "message/rfc822":
+		if msg.forwarded != nil {
+			return nil
+		}
+		data, err := io.ReadAll(decodeTransferEncoding(body, encoding))
+		if err != nil {
+			return err
+		}
+		forwarded, err := parseMessage(data)
+		if err != nil {
+			return fmt.Errorf("failed to parse forwarded message: %w", err)
+		}
+		msg.forwarded = forwarded
+	case mediaType == "text/plain" && msg.text == "":
+		text, err := readText(body, encoding, params["charset"])
+		if err != nil {
+			return err
+		}
+		msg.text = text
+	case mediaType == "text/html" && msg.html == "":
+		text, err := readText(body, encoding, params["charset"])
+		if err != nil {
+			return err
+		}
+		msg.html = text
+	}
+	return nil
+}
+
+func readText(body io.Reader, encoding, charsetLabel string) (string, error) {
+	r := decodeTransferEncoding(body, encoding)
+	if charsetLabel != "" && !strings.EqualFold(charsetLabel, "utf-8") && !strings.EqualFold(charsetLabel, "us-ascii") {
+		cr, err := charset.NewReaderLabel(charsetLabel, r)
+		if err != nil {
+			return "", fmt.Errorf("unsupported charset %q", charsetLabel)
+		}
+		r = cr
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode body: %w", err)
+	}
+	return string(data), nil
+}
+
+func decodeTransferEncoding(body io.Reader, encoding string) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, &newlineSkipper{r: body})
+	case "quoted-printable":
+		return quotedprintable.NewReader(body)
+	}
+	return body
+}
+
+// newlineSkipper removes the line breaks of base64 encoded bodies, which the base64 decoder doesn't accept.
+type newlineSkipper struct {
+	r io.Reader
+}
+
+func (s *newlineSkipper) Read(p []byte) (int, error) {
+	n, err := s.r.Read(p)
+	kept := 0
+	for _, b := range p[:n] {
+		if b != '\r' && b != '\n' {
+			p[kept] = b
+			kept++
+		}
+	}
+	return kept, err
+}
+
+var urlPattern = regexp.MustCompile(`https?://[^\s<>"'()\[\]]+`)
+
+// links returns the unique HTTP URLs in the text.
+func links(text string) []string {
+	var urls []string
+	seen := make(map[string]bool)
+	for _, u := range urlPattern.FindAllString(text, -1) {
+		u = strings.TrimRight(u, ".,;:!?")
+		if !seen[u] {
+			seen[u] = true
+			urls = append(urls, u)
+		}
+	}
+	return urls
+}
+
+// plainText returns the text body or the text of the HTML body if there is none.
+func (msg *message) plainText() string {
+	if msg.text != "" || msg.html == "" {
+		return msg.text
+	}
+	doc, err := dom.FastParse(strings.NewReader(msg.html))
+	if err != nil {
+		return ""
+	}
+	return dom.TextContent(doc)
+}
+
+// links returns the links of the email.
+// Links in HTML bodies are often hidden behind their text, so they are taken from the anchors as well.
+func (msg *message) links() []string {
+	text := msg.plainText()
+	if msg.text == "" && msg.html != "" {
+		if doc, err := dom.FastParse(strings.NewReader(msg.html)); err == nil {
+			for _, a := range dom.GetElementsByTagName(doc, "a") {
+				text += "\n" + dom.GetAttribute(a, "href")
+			}
+		}
+	}
+	return links(text)
+}
+
+// wordsBesidesLinks returns the number of words of the text without the links.
+func (msg *message) wordsBesidesLinks() int {
+	return len(strings.Fields(urlPattern.ReplaceAllString(msg.plainText(), "")))
+}
+
+var subjectPrefixPattern = regexp.MustCompile(`(?i)^\s*((fwd?|wg|tr|re|aw)\s*:\s*)+`)
+
+// title returns the subject without the prefixes of forwards and replies.
+func (msg *message) title() string {
+	return strings.TrimSpace(subjectPrefixPattern.ReplaceAllString(msg.subject, ""))
+}
+
+// author returns the name of the sender or the address if it has no name.
+func (msg *message) author() string {
+	if msg.from == nil {
+		return ""
+	}
+	if msg.from.Name != "" {
+		return msg.from.Name
+	}
+	return msg.from.Address
+}
@@ -0,0 +1,235 @@
+package inbox
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Protocol is the protocol the listener speaks.
+type Protocol string
+
+const (
+	// ProtocolSMTP is used when mail servers deliver directly to influss.
+	ProtocolSMTP Protocol = "smtp"
+	// ProtocolLMTP is used when a local mail server like Postfix or Dovecot hands mail over to influss.
+	ProtocolLMTP Protocol = "lmtp"
+)
+
+// commandTimeout is how long to wait for the next command or the message data.
+const commandTimeout = 5 * time.Minute
+
+// ListenAndServe accepts connections on the configured address until the context is canceled.
+func (s *Server) ListenAndServe(ctx context.Context) error {
+	ln, err := net.Listen("tcp", s.cfg.Addr)
+	if err != nil {
+		return err
+	}
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+	}()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				time.Sleep(time.Second)
+				continue
+			}
+			return err
+		}
+		go s.serveConn(ctx, conn)
+	}
+}
+
+// session is the state of a mail transaction.
+type session struct {
+	greeted bool
+	// mail is set by MAIL, the sender itself may be empty for bounces
+	mail       bool
+	from       string
+	recipients []string
+}
+
+func (s *Server) serveConn(ctx context.Context, conn net.Conn) {
+	defer conn.Close()
+	log := s.log.With(slog.String("remote_addr", conn.RemoteAddr().String()))
+
+	r := textproto.NewReader(bufio.NewReader(conn))
+	w := textproto.NewWriter(bufio.NewWriter(conn))
+	reply := func(format string, args ...any) bool {
+		conn.SetWriteDeadline(time.Now().Add(commandTimeout))
+		return w.PrintfLine(format, args...) == nil
+	}
+
+	greeting := "ESMTP"
+	if s.cfg.Protocol == ProtocolLMTP {
+		greeting = "LMTP"
+	}
+	if !reply("220 %s %s influss ready", s.hostname, greeting) {
+		return
+	}
+
+	var sess session
+	for {
+		conn.SetReadDeadline(time.Now().Add(commandTimeout))
+		line, err := r.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		verb = strings.ToUpper(verb)
+
+		switch {
+		case verb == "HELO" && s.cfg.Protocol == ProtocolSMTP:
+			sess = session{greeted: true}
+			reply("250 %s", s.hostname)
+		case (verb == "EHLO" && s.cfg.Protocol == ProtocolSMTP) || (verb == "LHLO" && s.cfg.Protocol == ProtocolLMTP):
+			sess = session{greeted: true}
+			reply("250-%s", s.hostname)
+			reply("250-PIPELINING")
+			reply("250-8BITMIME")
+			reply("250 SIZE %d", s.cfg.MaxMessageSize)
+		case verb == "MAIL":
+			if !sess.greeted {
+				reply("503 5.5.1 Say hello first")
+				continue
+			}
+			from, params, ok := parsePath(arg, "FROM:")
+			if !ok {
+				reply("501 5.5.4 Syntax: MAIL FROM:<address>")
+				continue
+			}
+			if size, err := strconv.ParseInt(params["SIZE"], 10, 64); err == nil && size > s.cfg.MaxMessageSize {
+				reply("552 5.3.4 Message too big")
+				continue
+			}
+			sess.mail = true
+			sess.from = from
+			sess.recipients = nil
+			reply("250 2.1.0 OK")
+		case verb == "RCPT":
+			if !sess.mail {
+				reply("503 5.5.1 Need MAIL first")
+				continue
+			}
+			to, _, ok := parsePath(arg, "TO:")
+			if !ok {
+				reply("501 5.5.4 Syntax: RCPT TO:<address>")
+				continue
+			}
+			if !s.acceptsRecipient(to) {
+				log.Warn("Rejected mail to unknown recipient", slog.String("to", to))
+				reply("550 5.1.1 No such recipient")
+				continue
+			}
+			sess.recipients = append(sess.recipients, to)
+			reply("250 2.1.5 OK")
+		case verb == "DATA":
+			if len(sess.recipients) == 0 {
+				reply("503 5.5.1 Need RCPT first")
+				continue
+			}
+			if !reply("354 End data with <CR><LF>.<CR><LF>") {
+				return
+			}
+			conn.SetReadDeadline(time.Now().Add(commandTimeout))
+			code, msg := s.receive(ctx, log, r.DotReader())
+			// LMTP replies once per recipient, the message is only stored once nonetheless
+			n := 1
+			if s.cfg.Protocol == ProtocolLMTP {
+				n = len(sess.recipients)
+			}
+			for range n {
+				reply("%d %s", code, msg)
+			}
+			sess = session{greeted: true}
+		case verb == "RSET":
+			sess = session{greeted: sess.greeted}
+			reply("250 2.0.0 OK")
+		case verb == "NOOP":
+			reply("250 2.0.0 OK")
+		case verb == "VRFY":
+			reply("252 2.5.0 Cannot verify user")
+		case verb == "QUIT":
+			reply("221 2.0.0 Bye")
+			return
+		default:
+			reply("502 5.5.2 Command not implemented")
+		}
+	}
+}
+
+// receive reads the message data and stores the message.
+// It returns the reply code and message for the client.
+func (s *Server) receive(ctx context.Context, log *slog.Logger, data io.Reader) (int, string) {
+	buf, err := io.ReadAll(io.LimitReader(data, s.cfg.MaxMessageSize+1))
+	// the rest of the data must be read anyway to get back to the commands
+	io.Copy(io.Discard, data)
+	if err != nil {
+		return 451, "4.3.0 Failed to read message"
+	}
+	if int64(len(buf)) > s.cfg.MaxMessageSize {
+		return 552, "5.3.4 Message too big"
+	}
+
+	err = s.deliver(ctx, buf)
+	var rejected *rejectError
+	switch {
+	case errors.As(err, &rejected):
+		log.Warn("Rejected mail", slog.String("reason", rejected.reason))
+		return 550, "5.7.1 " + rejected.reason
+	case err != nil:
+		log.Error("Failed to store mail", slog.String("error", err.Error()))
+		return 451, "4.3.0 Failed to store message, try again later"
+	}
+	return 250, "2.0.0 OK"
+}
+
+// parsePath parses the argument of MAIL and RCPT like `FROM:<a@example.com> SIZE=1234`.
+func parsePath(arg, prefix string) (string, map[string]string, bool) {
+	if len(arg) < len(prefix) || !strings.EqualFold(arg[:len(prefix)], prefix) {
+		return "", nil, false
+	}
+	arg = strings.TrimSpace(arg[len(prefix):])
+	if !strings.HasPrefix(arg, "<") {
+		return "", nil, false
+	}
+	addr, rest, ok := strings.Cut(arg[1:], ">")
+	if !ok {
+		return "", nil, false
+	}
+
+	params := make(map[string]string)
+	for _, p := range strings.Fields(rest) {
+		k, v, _ := strings.Cut(p, "=")
+		params[strings.ToUpper(k)] = v
+	}
+	return addr, params, true
+}
+
+// rejectError is returned for messages which are refused permanently.
+type rejectError struct {
+	reason string
+}
+
+func (e *rejectError) Error() string {
+	return e.reason
+}
+
+func reject(format string, args ...any) error {
+	return &rejectError{reason: fmt.Sprintf(format, args...)}
+}
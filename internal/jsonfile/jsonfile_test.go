@@ -0,0 +1,53 @@
+package jsonfile_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/timofurrer/influss/internal/jsonfile"
+)
+
+func TestWrite(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "state.json")
+
+	for _, v := range []map[string]int{{"first": 1}, {"second": 2}} {
+		if err := jsonfile.Write(path, v); err != nil {
+			t.Fatalf("failed to write file: %v", err)
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read file: %v", err)
+	}
+	if want := "{\n  \"second\": 2\n}"; string(data) != want {
+		t.Errorf("expected %q, got %q", want, data)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("expected only the written file, got %d files", len(entries))
+	}
+}
+
+func TestWriteKeepsFileOnError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	if err := jsonfile.Write(path, "kept"); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	if err := jsonfile.Write(path, func() {}); err == nil {
+		t.Fatal("expected writing a function to fail")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read file: %v", err)
+	}
+	if string(data) != `"kept"` {
+		t.Errorf("expected the file to be kept, got %q", data)
+	}
+}
@@ -0,0 +1,187 @@
+// Package retention prunes old clips according to retention rules, so that the stores don't grow forever.
+// Tagged and starred clips are never pruned.
+package retention
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/timofurrer/influss/internal/clip"
+	"github.com/timofurrer/influss/internal/store"
+)
+
+// Action is what happens to the pruned clips.
+type Action string
+
+const (
+	// ActionDelete deletes the clips.
+	ActionDelete Action = "delete"
+	// ActionArchive marks the clips as read, like archiving them in wallabag.
+	ActionArchive Action = "archive"
+)
+
+var Actions = []Action{ActionDelete, ActionArchive}
+
+type Config struct {
+	// MaxAge prunes the clips clipped longer ago, 0 disables the rule.
+	MaxAge time.Duration
+	// MaxClips prunes the oldest clips beyond this number of prunable clips, 0 disables the rule.
+	// Protected clips don't count, when archiving neither do read clips.
+	MaxClips int
+	Action   Action
+}
+
+// Enabled checks if any retention rule is configured.
+func (c Config) Enabled() bool {
+	return c.MaxAge > 0 || c.MaxClips > 0
+}
+
+// orphanRemover is implemented by stores which keep files for their clips, like the file system store.
+type orphanRemover interface {
+	RemoveOrphanedFiles(dryRun bool) ([]string, error)
+}
+
+// findOrphanRemover returns the store or the store wrapped by it which removes orphaned files.
+func findOrphanRemover(s store.Store) (orphanRemover, bool) {
+	for {
+		if r, ok := s.(orphanRemover); ok {
+			return r, true
+		}
+		w, ok := s.(interface{ Unwrap() store.Store })
+		if !ok {
+			return nil, false
+		}
+		s = w.Unwrap()
+	}
+}
+
+// Janitor applies the retention rules to the clips of a store.
+type Janitor struct {
+	log   *slog.Logger
+	cfg   Config
+	store store.Store
+}
+
+func New(log *slog.Logger, cfg Config, store store.Store) *Janitor {
+	return &Janitor{log: log, cfg: cfg, store: store}
+}
+
+// Result are the clips and files pruned by the janitor.
+type Result struct {
+	Clips []*clip.Clip
+	Files []string
+}
+
+// Run prunes the clips every interval until the context is canceled.
+func (j *Janitor) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		res, err := j.Prune(ctx, false)
+		if err != nil {
+			j.log.Error("Failed to prune clips", slog.String("error", err.Error()))
+		} else if len(res.Clips) > 0 || len(res.Files) > 0 {
+			j.log.Info("Pruned clips", slog.Int("clips", len(res.Clips)), slog.Int("files", len(res.Files)))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Prune applies the retention rules and removes orphaned files of the store.
+// With dryRun nothing is changed, the clips and files which would be pruned are only returned.
+func (j *Janitor) Prune(ctx context.Context, dryRun bool) (Result, error) {
+	clipMsg, fileMsg := "Pruned clip", "Removed orphaned file"
+	if dryRun {
+		clipMsg, fileMsg = "Would prune clip", "Would remove orphaned file"
+	}
+
+	var res Result
+	if j.cfg.Enabled() {
+		clips, err := j.prunable(ctx)
+		if err != nil {
+			return res, err
+		}
+		for _, c := range clips {
+			if !dryRun {
+				if err := j.prune(ctx, c); err != nil {
+					return res, fmt.Errorf("failed to prune clip %s: %w", c.URL, err)
+				}
+			}
+			j.log.Info(clipMsg, slog.String("url", c.URL), slog.Time("clipped_at", c.ClippedAt), slog.String("action", string(j.cfg.Action)))
+			res.Clips = append(res.Clips, c)
+		}
+	}
+
+	if r, ok := findOrphanRemover(j.store); ok {
+		files, err := r.RemoveOrphanedFiles(dryRun)
+		for _, f := range files {
+			j.log.Info(fileMsg, slog.String("path", f))
+		}
+		res.Files = files
+		if err != nil {
+			return res, err
+		}
+	}
+	return res, nil
+}
+
+// prunable returns the clips matching the retention rules, newest first.
+func (j *Janitor) prunable(ctx context.Context) ([]*clip.Clip, error) {
+	q := store.Query{}
+	if j.cfg.Action == ActionArchive {
+		q.Read = new(bool)
+	}
+	clips, _, err := j.store.List(ctx, q)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list clips: %w", err)
+	}
+
+	var prunable []*clip.Clip
+	kept := 0
+	for _, c := range clips {
+		if protected(c) {
+			continue
+		}
+		tooOld := j.cfg.MaxAge > 0 && time.Since(c.ClippedAt) > j.cfg.MaxAge
+		tooMany := j.cfg.MaxClips > 0 && kept >= j.cfg.MaxClips
+		if tooOld || tooMany {
+			prunable = append(prunable, c)
+			continue
+		}
+		kept++
+	}
+	return prunable, nil
+}
+
+// protected checks if the clip must never be pruned.
+func protected(c *clip.Clip) bool {
+	return len(c.Tags) > 0 || c.Starred
+}
+
+// prune applies the action to the clip, clips deleted in the meantime are skipped.
+func (j *Janitor) prune(ctx context.Context, c *clip.Clip) error {
+	var err error
+	switch j.cfg.Action {
+	case ActionArchive:
+		// listed clips come without their content, which would be lost when storing them
+		var full *clip.Clip
+		if full, err = j.store.Get(ctx, c.URL); err == nil {
+			full.Read = true
+			err = j.store.Store(ctx, full)
+		}
+	default:
+		err = j.store.Delete(ctx, c.URL)
+	}
+	if errors.Is(err, store.ErrNotFound) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,133 @@
+package retention_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/timofurrer/influss/internal/clip"
+	"github.com/timofurrer/influss/internal/retention"
+	"github.com/timofurrer/influss/internal/store"
+)
+
+func TestPruneRemovesOrphanedFilesOfHookedStore(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	fs, err := store.NewFSStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := store.WithChangeHooks(fs, func(context.Context, store.Change) {})
+	if err := s.Store(ctx, &clip.Clip{URL: "https://example.com/kept", Title: "Kept", ClippedAt: time.Now()}); err != nil {
+		t.Fatalf("failed to store clip: %v", err)
+	}
+
+	// orphaned files are only removed after a grace period
+	h := sha256.Sum256([]byte("https://example.com/orphaned"))
+	orphan := filepath.Join(dir, hex.EncodeToString(h[:])+".json")
+	if err := os.WriteFile(orphan, []byte("{}"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(orphan, old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	j := retention.New(slog.New(slog.NewTextHandler(io.Discard, nil)), retention.Config{}, s)
+	res, err := j.Prune(ctx, false)
+	if err != nil {
+		t.Fatalf("failed to prune: %v", err)
+	}
+	if len(res.Files) != 1 || res.Files[0] != orphan {
+		t.Errorf("expected orphaned file %s to be removed, got %v", orphan, res.Files)
+	}
+	if _, err := os.Stat(orphan); !os.IsNotExist(err) {
+		t.Errorf("expected orphaned file to be gone, got %v", err)
+	}
+	if _, err := s.Get(ctx, "https://example.com/kept"); err != nil {
+		t.Errorf("expected stored clip to be kept, got %v", err)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	const day = 24 * time.Hour
+	tests := []struct {
+		name   string
+		cfg    retention.Config
+		dryRun bool
+		// pruned are the clips pruned by the rules, newest first
+		pruned []string
+	}{
+		{"disabled", retention.Config{Action: retention.ActionDelete}, false, nil},
+		{"max age", retention.Config{MaxAge: 30 * day, Action: retention.ActionDelete}, false, []string{"month", "read", "year"}},
+		{"max clips", retention.Config{MaxClips: 2, Action: retention.ActionDelete}, false, []string{"month", "read", "year"}},
+		{"max clips beyond clips", retention.Config{MaxClips: 10, Action: retention.ActionDelete}, false, nil},
+		{"max age and max clips", retention.Config{MaxAge: 30 * day, MaxClips: 1, Action: retention.ActionDelete}, false, []string{"week", "month", "read", "year"}},
+		{"archive max age", retention.Config{MaxAge: 30 * day, Action: retention.ActionArchive}, false, []string{"month", "year"}},
+		// read clips don't count when archiving
+		{"archive max clips", retention.Config{MaxClips: 3, Action: retention.ActionArchive}, false, []string{"year"}},
+		{"dry run delete", retention.Config{MaxAge: 30 * day, Action: retention.ActionDelete}, true, []string{"month", "read", "year"}},
+		{"dry run archive", retention.Config{MaxClips: 1, Action: retention.ActionArchive}, true, []string{"week", "month", "year"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			s := store.NewMemoryStore()
+			clips := map[string]*clip.Clip{
+				"new":     {ClippedAt: time.Now().Add(-day)},
+				"week":    {ClippedAt: time.Now().Add(-8 * day)},
+				"month":   {ClippedAt: time.Now().Add(-40 * day)},
+				"read":    {ClippedAt: time.Now().Add(-45 * day), Read: true},
+				"year":    {ClippedAt: time.Now().Add(-400 * day)},
+				"tagged":  {ClippedAt: time.Now().Add(-500 * day), Tags: []string{"keep"}},
+				"starred": {ClippedAt: time.Now().Add(-500 * day), Starred: true},
+			}
+			for name, c := range clips {
+				c.URL = "https://example.com/" + name
+				c.Title = name
+				c.HTMLContent = "<p>" + name + "</p>"
+				if err := s.Store(ctx, c); err != nil {
+					t.Fatalf("failed to store clip: %v", err)
+				}
+			}
+
+			j := retention.New(slog.New(slog.NewTextHandler(io.Discard, nil)), tt.cfg, s)
+			res, err := j.Prune(ctx, tt.dryRun)
+			if err != nil {
+				t.Fatalf("failed to prune: %v", err)
+			}
+			var pruned []string
+			for _, c := range res.Clips {
+				pruned = append(pruned, c.Title)
+			}
+			if !slices.Equal(pruned, tt.pruned) {
+				t.Errorf("expected pruned clips %v, got %v", tt.pruned, pruned)
+			}
+
+			for name, want := range clips {
+				got, err := s.Get(ctx, want.URL)
+				isPruned := slices.Contains(tt.pruned, name) && !tt.dryRun
+				switch {
+				case isPruned && tt.cfg.Action == retention.ActionDelete:
+					if !errors.Is(err, store.ErrNotFound) {
+						t.Errorf("expected clip %s to be deleted, got %v", name, err)
+					}
+				case err != nil:
+					t.Errorf("expected clip %s to be kept, got %v", name, err)
+				case got.Read != (want.Read || isPruned):
+					t.Errorf("expected clip %s to be read %t, got %t", name, want.Read || isPruned, got.Read)
+				case got.HTMLContent != want.HTMLContent:
+					t.Errorf("expected content of clip %s to be kept, got %q", name, got.HTMLContent)
+				}
+			}
+		})
+	}
+}
@@ -2,13 +2,112 @@ package store
 
 import (
 	"context"
+	"errors"
+	"net/url"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/timofurrer/influss/internal/clip"
 )
 
+// ErrNotFound is returned when a clip does not exist in the store.
+var ErrNotFound = errors.New("clip not found")
+
 type Store interface {
 	CreatedAt() time.Time
 	Store(ctx context.Context, clip *clip.Clip) error
 	Load(ctx context.Context, lastN int) []*clip.Clip
+	// Get returns the clip with the given URL including its plain text and markdown content.
+	Get(ctx context.Context, url string) (*clip.Clip, error)
+	// GetByID returns the clip with the given id including its plain text and markdown content.
+	GetByID(ctx context.Context, id int64) (*clip.Clip, error)
+	// List returns the clips matching the query, newest first, and the total number of matching clips.
+	List(ctx context.Context, q Query) ([]*clip.Clip, int, error)
+	// Delete deletes the clip with the given URL.
+	Delete(ctx context.Context, url string) error
+	// Subscribe returns a channel receiving the changes of clips until the context is canceled.
+	// Events are dropped while the subscriber doesn't keep up.
+	Subscribe(ctx context.Context) <-chan Event
+}
+
+// Query filters and paginates the clips returned by Store.List.
+type Query struct {
+	// Tags restricts the clips to the ones having all of the given tags.
+	Tags []string
+	// Read restricts the clips to read or unread clips, if set.
+	Read *bool
+	// Starred restricts the clips to starred or unstarred clips, if set.
+	Starred *bool
+	// Since restricts the clips to the ones clipped after the given time.
+	Since time.Time
+	// Until restricts the clips to the ones clipped before the given time.
+	Until time.Time
+	// Domain restricts the clips to the ones with a URL on the given domain or its subdomains.
+	Domain string
+	// Search restricts the clips to the ones containing the given text in
+	// their title, author, URL, excerpt or site name, ignoring case.
+	Search string
+	// MaxReadingMinutes restricts the clips to the ones read in at most the given minutes,
+	// clips without a reading time are included. 0 means no restriction.
+	MaxReadingMinutes int
+	// Ascending orders the clips oldest first.
+	Ascending bool
+	Offset    int
+	// Limit is the maximum number of clips to return, 0 means no limit.
+	Limit int
+}
+
+// matches checks if the given clip matches the filters of the query.
+func (q Query) matches(c *clip.Clip) bool {
+	if q.Read != nil && c.Read != *q.Read {
+		return false
+	}
+	if q.Starred != nil && c.Starred != *q.Starred {
+		return false
+	}
+	if !q.Since.IsZero() && !c.ClippedAt.After(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && !c.ClippedAt.Before(q.Until) {
+		return false
+	}
+	for _, t := range q.Tags {
+		if !slices.Contains(c.Tags, t) {
+			return false
+		}
+	}
+	return true
+}
+
+// needsDetails checks if the query filters on fields which are only available on the full clip.
+func (q Query) needsDetails() bool {
+	return q.Search != "" || q.Domain != "" || q.MaxReadingMinutes > 0
+}
+
+// matchesDetails checks if the given clip matches the search text, domain and reading time of the query.
+func (q Query) matchesDetails(c *clip.Clip) bool {
+	if q.MaxReadingMinutes > 0 && c.ReadingTimeMinutes > q.MaxReadingMinutes {
+		return false
+	}
+	if q.Domain != "" {
+		u, err := url.Parse(c.URL)
+		if err != nil {
+			return false
+		}
+		host, domain := strings.ToLower(u.Hostname()), strings.ToLower(q.Domain)
+		if host != domain && !strings.HasSuffix(host, "."+domain) {
+			return false
+		}
+	}
+	if q.Search == "" {
+		return true
+	}
+	search := strings.ToLower(q.Search)
+	for _, field := range []string{c.Title, c.Author, c.URL, c.Excerpt, c.SiteName} {
+		if strings.Contains(strings.ToLower(field), search) {
+			return true
+		}
+	}
+	return false
 }
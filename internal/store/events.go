@@ -0,0 +1,55 @@
+package store
+
+import (
+	"context"
+	"sync"
+)
+
+// eventBuffer is the number of events buffered per subscriber, further events are dropped until it catches up.
+const eventBuffer = 256
+
+// Event is a change of a clip delivered to the subscribers of the store.
+type Event struct {
+	Type ChangeType `json:"type"`
+	URL  string     `json:"url"`
+}
+
+// broadcaster delivers events to the subscribers within the process.
+// The zero value is ready to use.
+type broadcaster struct {
+	mu   sync.Mutex
+	subs map[chan Event]struct{}
+}
+
+// subscribe returns a channel receiving the published events, which is closed when the context is canceled.
+func (b *broadcaster) subscribe(ctx context.Context) <-chan Event {
+	ch := make(chan Event, eventBuffer)
+
+	b.mu.Lock()
+	if b.subs == nil {
+		b.subs = make(map[chan Event]struct{})
+	}
+	b.subs[ch] = struct{}{}
+	b.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		b.mu.Lock()
+		delete(b.subs, ch)
+		close(ch)
+		b.mu.Unlock()
+	}()
+	return ch
+}
+
+// publish delivers the event to all subscribers without blocking.
+func (b *broadcaster) publish(e Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subs {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
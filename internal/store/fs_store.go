@@ -1,6 +1,7 @@
 package store
 
 import (
+	"cmp"
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
@@ -12,6 +13,7 @@ import (
 	"os"
 	"path/filepath"
 	"slices"
+	"strings"
 	"sync"
 	"time"
 
@@ -26,18 +28,32 @@ type FSStore struct {
 	dir   string
 	index *index
 	m     sync.RWMutex
+
+	events broadcaster
 }
 
+// indexVersion is the current version of the index file format.
+// Older indices are upgraded when the store is opened.
+const indexVersion = 3
+
 type index struct {
+	Version       int                 `json:"version"`
 	CreatedAt     time.Time           `json:"created_at"`
 	LastUpdatedAt time.Time           `json:"last_updated_at"`
+	NextID        int64               `json:"next_id"`
 	Clips         map[string]clipMeta `json:"clips"`
 }
 
+// clipMeta is the index entry of a clip.
+// It contains everything needed to filter clips without reading the clip files.
 type clipMeta struct {
+	ID        int64     `json:"id"`
 	Hash      string    `json:"hash"`
 	Path      string    `json:"path"`
 	Timestamp time.Time `json:"timestamp"`
+	Tags      []string  `json:"tags,omitempty"`
+	Read      bool      `json:"read,omitempty"`
+	Starred   bool      `json:"starred,omitempty"`
 }
 
 type fsClip struct {
@@ -48,6 +64,18 @@ type fsClip struct {
 	Author      string    `json:"author"`
 	Excerpt     string    `json:"excerpt"`
 	HTMLContent string    `json:"html_content"`
+
+	WordCount          int    `json:"word_count"`
+	ReadingTimeMinutes int    `json:"reading_time_minutes"`
+	Language           string `json:"language"`
+
+	Image    string `json:"image,omitempty"`
+	SiteName string `json:"site_name,omitempty"`
+	Favicon  string `json:"favicon,omitempty"`
+
+	Tags    []string `json:"tags,omitempty"`
+	Read    bool     `json:"read,omitempty"`
+	Starred bool     `json:"starred,omitempty"`
 }
 
 func NewFSStore(dir string) (*FSStore, error) {
@@ -59,11 +87,12 @@ func NewFSStore(dir string) (*FSStore, error) {
 	data, err := os.ReadFile(indexFile)
 	if err != nil {
 		return &FSStore{
-			dir:   dir,
+			dir: dir,
 			index: &index{
-				CreatedAt: time.Now(),
+				Version:       indexVersion,
+				CreatedAt:     time.Now(),
 				LastUpdatedAt: time.Now(),
-				Clips: make(map[string]clipMeta),
+				Clips:         make(map[string]clipMeta),
 			},
 		}, nil
 	}
@@ -73,10 +102,80 @@ func NewFSStore(dir string) (*FSStore, error) {
 		return nil, err
 	}
 
-	return &FSStore{
+	s := &FSStore{
 		dir:   dir,
 		index: index,
-	}, nil
+	}
+	if err := s.upgrade(); err != nil {
+		return nil, fmt.Errorf("failed to upgrade store: %w", err)
+	}
+	return s, nil
+}
+
+// upgrade migrates the clips of an index written by an older version of the store.
+func (s *FSStore) upgrade() error {
+	if s.index.Version >= indexVersion {
+		return nil
+	}
+
+	if s.index.Version < 1 {
+		// backfill reading metadata from the plain text content files
+		for h, cm := range s.index.Clips {
+			fc := &fsClip{}
+			if err := readJSON(fc, cm.Path); err != nil {
+				return fmt.Errorf("failed to read clip %s: %w", h, err)
+			}
+			text, err := os.ReadFile(s.contentPath(h, "txt"))
+			if err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("failed to read clip %s plain text data: %w", h, err)
+			}
+			m := clip.ComputeMetadata(string(text))
+			fc.WordCount = m.WordCount
+			fc.ReadingTimeMinutes = m.ReadingTimeMinutes
+			fc.Language = cmp.Or(fc.Language, m.Language)
+			if err := writeJSON(fc, cm.Path); err != nil {
+				return fmt.Errorf("failed to write clip %s: %w", h, err)
+			}
+		}
+	}
+
+	if s.index.Version < 2 {
+		// write markdown content files converted from the HTML content
+		for h, cm := range s.index.Clips {
+			fc := &fsClip{}
+			if err := readJSON(fc, cm.Path); err != nil {
+				return fmt.Errorf("failed to read clip %s: %w", h, err)
+			}
+			md, err := clip.HTMLToMarkdown(fc.HTMLContent)
+			if err != nil {
+				return fmt.Errorf("failed to convert clip %s to markdown: %w", h, err)
+			}
+			if err := os.WriteFile(s.contentPath(h, "md"), []byte(md), 0644); err != nil {
+				return fmt.Errorf("failed to store clip %s markdown data: %w", h, err)
+			}
+		}
+	}
+
+	if s.index.Version < 3 {
+		// assign ids in the order the clips were stored and copy the filterable fields to the index
+		cs := slices.SortedFunc(maps.Values(s.index.Clips), func(a, b clipMeta) int {
+			return a.Timestamp.Compare(b.Timestamp)
+		})
+		for _, cm := range cs {
+			fc := &fsClip{}
+			if err := readJSON(fc, cm.Path); err != nil {
+				return fmt.Errorf("failed to read clip %s: %w", cm.Hash, err)
+			}
+			s.index.NextID++
+			cm.ID = s.index.NextID
+			cm.Tags = fc.Tags
+			cm.Read = fc.Read
+			s.index.Clips[cm.Hash] = cm
+		}
+	}
+
+	s.index.Version = indexVersion
+	return writeJSON(s.index, filepath.Join(s.dir, "index.json"))
 }
 
 func (s *FSStore) CreatedAt() time.Time {
@@ -86,21 +185,27 @@ func (s *FSStore) CreatedAt() time.Time {
 func (s *FSStore) Store(_ context.Context, clip *clip.Clip) error {
 	s.m.Lock()
 	defer s.m.Unlock()
-	fc := fsClip{
-		URL:         clip.URL,
-		Title:       clip.Title,
-		Author:      clip.Author,
-		PublishedAt: clip.PublishedAt,
-		ModifiedAt:  clip.ModifiedAt,
-		Excerpt:     clip.Excerpt,
-		HTMLContent: clip.HTMLContent,
-	}
+	fc := newFSClip(clip)
 
 	h := generateClipHash(clip)
+	existing, exists := s.index.Clips[h]
+	id := existing.ID
+	if id == 0 {
+		s.index.NextID++
+		id = s.index.NextID
+	}
 	cm := clipMeta{
+		ID:        id,
 		Hash:      h,
 		Path:      filepath.Join(s.dir, fmt.Sprintf("%s.json", h)),
-		Timestamp: time.Now(),
+		Timestamp: cmp.Or(clip.ClippedAt, time.Now()),
+		Tags:      clip.Tags,
+		Read:      clip.Read,
+		Starred:   clip.Starred,
+	}
+	if exists {
+		// like the SQL store, updating a clip keeps the time it was clipped
+		cm.Timestamp = existing.Timestamp
 	}
 
 	// write clip file
@@ -109,18 +214,32 @@ func (s *FSStore) Store(_ context.Context, clip *clip.Clip) error {
 		return fmt.Errorf("failed to store clip: %w", err)
 	}
 	// write plain text content file for better shell-friendliness
-	err = os.WriteFile(filepath.Join(s.dir, fmt.Sprintf("%s.txt", h)), []byte(clip.PlainTextContent), 0644)
+	err = os.WriteFile(s.contentPath(h, "txt"), []byte(clip.PlainTextContent), 0644)
 	if err != nil {
 		return fmt.Errorf("failed to store clip plain text data: %w", err)
 	}
+	// write markdown content file for notes tools
+	err = os.WriteFile(s.contentPath(h, "md"), []byte(clip.MarkdownContent), 0644)
+	if err != nil {
+		return fmt.Errorf("failed to store clip markdown data: %w", err)
+	}
 
-	s.index.LastUpdatedAt = cm.Timestamp
+	s.index.LastUpdatedAt = time.Now()
 	s.index.Clips[h] = cm
 
 	err = writeJSON(s.index, filepath.Join(s.dir, "index.json"))
 	if err != nil {
 		return fmt.Errorf("failed to store index file after updating clip %s: %w", h, err)
 	}
+
+	clip.ID = cm.ID
+	clip.ClippedAt = cm.Timestamp
+
+	event := Event{Type: ChangeCreated, URL: clip.URL}
+	if exists {
+		event.Type = ChangeUpdated
+	}
+	s.events.publish(event)
 	return nil
 }
 
@@ -137,30 +256,246 @@ func (s *FSStore) Load(_ context.Context, lastN int) []*clip.Clip {
 	clips := make([]*clip.Clip, 0, len(cs))
 
 	for _, cm := range cs {
-		data, err := os.ReadFile(cm.Path)
+		// NOTE: no need to load the plain text and markdown content files
+		c, err := s.load(cm)
 		if err != nil {
-			log.Error("Unable to load clip", slog.String("clip_hash", cm.Hash))
+			log.Error("Unable to load clip", slog.String("clip_hash", cm.Hash), slog.String("error", err.Error()))
 			continue
 		}
-		c := &fsClip{}
-		err = json.Unmarshal(data, c)
+		clips = append(clips, c)
+	}
+	return clips
+}
+
+func (s *FSStore) Get(_ context.Context, url string) (*clip.Clip, error) {
+	s.m.RLock()
+	defer s.m.RUnlock()
+
+	cm, ok := s.index.Clips[generateClipHash(&clip.Clip{URL: url})]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return s.loadWithContent(cm)
+}
+
+func (s *FSStore) GetByID(_ context.Context, id int64) (*clip.Clip, error) {
+	s.m.RLock()
+	defer s.m.RUnlock()
+
+	for _, cm := range s.index.Clips {
+		if cm.ID == id {
+			return s.loadWithContent(cm)
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (s *FSStore) List(_ context.Context, q Query) ([]*clip.Clip, int, error) {
+	s.m.RLock()
+	defer s.m.RUnlock()
+
+	var cs []clipMeta
+	for _, cm := range s.index.Clips {
+		if q.matches(&clip.Clip{ClippedAt: cm.Timestamp, Tags: cm.Tags, Read: cm.Read, Starred: cm.Starred}) {
+			cs = append(cs, cm)
+		}
+	}
+	slices.SortFunc(cs, func(a, b clipMeta) int {
+		if q.Ascending {
+			return cmp.Or(a.Timestamp.Compare(b.Timestamp), cmp.Compare(a.ID, b.ID))
+		}
+		return cmp.Or(b.Timestamp.Compare(a.Timestamp), cmp.Compare(b.ID, a.ID))
+	})
+
+	// the searched fields aren't part of the index, so all candidates must be loaded
+	var loaded []*clip.Clip
+	if q.needsDetails() {
+		for _, cm := range cs {
+			c, err := s.load(cm)
+			if err != nil {
+				return nil, 0, fmt.Errorf("failed to load clip %s: %w", cm.Hash, err)
+			}
+			if q.matchesDetails(c) {
+				loaded = append(loaded, c)
+			}
+		}
+		total := len(loaded)
+		loaded = loaded[min(q.Offset, len(loaded)):]
+		if q.Limit > 0 {
+			loaded = loaded[:min(q.Limit, len(loaded))]
+		}
+		return loaded, total, nil
+	}
+
+	total := len(cs)
+	cs = cs[min(q.Offset, len(cs)):]
+	if q.Limit > 0 {
+		cs = cs[:min(q.Limit, len(cs))]
+	}
+
+	clips := make([]*clip.Clip, 0, len(cs))
+	for _, cm := range cs {
+		c, err := s.load(cm)
 		if err != nil {
-			log.Error("Unable to unmarshal clip", slog.String("clip_hash", cm.Hash))
+			return nil, 0, fmt.Errorf("failed to load clip %s: %w", cm.Hash, err)
+		}
+		clips = append(clips, c)
+	}
+	return clips, total, nil
+}
+
+func (s *FSStore) Delete(_ context.Context, url string) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	h := generateClipHash(&clip.Clip{URL: url})
+	cm, ok := s.index.Clips[h]
+	if !ok {
+		return ErrNotFound
+	}
+
+	delete(s.index.Clips, h)
+	s.index.LastUpdatedAt = time.Now()
+	if err := writeJSON(s.index, filepath.Join(s.dir, "index.json")); err != nil {
+		return fmt.Errorf("failed to store index file after deleting clip %s: %w", h, err)
+	}
+
+	for _, path := range []string{cm.Path, s.contentPath(h, "txt"), s.contentPath(h, "md")} {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to delete clip file %s: %w", path, err)
 		}
+	}
+	s.events.publish(Event{Type: ChangeDeleted, URL: url})
+	return nil
+}
 
-		clips = append(clips, &clip.Clip{
-			URL:         c.URL,
-			Title:       c.Title,
-			Author:      c.Author,
-			PublishedAt: c.PublishedAt,
-			ModifiedAt:  c.ModifiedAt,
-			Excerpt:     c.Excerpt,
-			HTMLContent: c.HTMLContent,
-			// NOTE: no need to load the plain text content file
-			PlainTextContent: "",
-		})
+func (s *FSStore) Subscribe(ctx context.Context) <-chan Event {
+	return s.events.subscribe(ctx)
+}
+
+// orphanGracePeriod is how long files of clips missing in the index are kept,
+// e.g. while another process sharing the store directory, like an import, writes them.
+const orphanGracePeriod = time.Hour
+
+// RemoveOrphanedFiles removes the files of clips which aren't in the index, e.g. left behind by an interrupted save,
+// and returns their paths. With dryRun the files are only returned.
+func (s *FSStore) RemoveOrphanedFiles(dryRun bool) ([]string, error) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read store directory: %w", err)
+	}
+
+	var orphans []string
+	for _, entry := range entries {
+		// clip files are named after the hash of the clip, like <hash>.json
+		h, _, _ := strings.Cut(entry.Name(), ".")
+		if !entry.Type().IsRegular() || !isClipHash(h) {
+			continue
+		}
+		if _, ok := s.index.Clips[h]; ok {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || time.Since(info.ModTime()) < orphanGracePeriod {
+			continue
+		}
+
+		path := filepath.Join(s.dir, entry.Name())
+		if !dryRun {
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				return orphans, fmt.Errorf("failed to remove orphaned file %s: %w", path, err)
+			}
+		}
+		orphans = append(orphans, path)
+	}
+	return orphans, nil
+}
+
+// load reads the clip file of the given index entry.
+func (s *FSStore) load(cm clipMeta) (*clip.Clip, error) {
+	fc := &fsClip{}
+	if err := readJSON(fc, cm.Path); err != nil {
+		return nil, err
+	}
+	c := fc.toClip()
+	c.ID = cm.ID
+	c.ClippedAt = cm.Timestamp
+	return c, nil
+}
+
+// loadWithContent reads the clip file of the given index entry including the plain text and markdown content files.
+func (s *FSStore) loadWithContent(cm clipMeta) (*clip.Clip, error) {
+	c, err := s.load(cm)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load clip %s: %w", cm.Hash, err)
+	}
+
+	text, err := os.ReadFile(s.contentPath(cm.Hash, "txt"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to load clip %s plain text data: %w", cm.Hash, err)
+	}
+	c.PlainTextContent = string(text)
+
+	md, err := os.ReadFile(s.contentPath(cm.Hash, "md"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to load clip %s markdown data: %w", cm.Hash, err)
+	}
+	c.MarkdownContent = string(md)
+
+	return c, nil
+}
+
+// contentPath returns the path to the content file with the given extension of the clip with the given hash.
+func (s *FSStore) contentPath(hash, ext string) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%s.%s", hash, ext))
+}
+
+func newFSClip(c *clip.Clip) fsClip {
+	return fsClip{
+		URL:         c.URL,
+		Title:       c.Title,
+		Author:      c.Author,
+		PublishedAt: c.PublishedAt,
+		ModifiedAt:  c.ModifiedAt,
+		Excerpt:     c.Excerpt,
+		HTMLContent: c.HTMLContent,
+
+		WordCount:          c.WordCount,
+		ReadingTimeMinutes: c.ReadingTimeMinutes,
+		Language:           c.Language,
+
+		Image:    c.Image,
+		SiteName: c.SiteName,
+		Favicon:  c.Favicon,
+
+		Tags:    c.Tags,
+		Read:    c.Read,
+		Starred: c.Starred,
+	}
+}
+
+func (fc *fsClip) toClip() *clip.Clip {
+	return &clip.Clip{
+		URL:                fc.URL,
+		Title:              fc.Title,
+		Author:             fc.Author,
+		PublishedAt:        fc.PublishedAt,
+		ModifiedAt:         fc.ModifiedAt,
+		Excerpt:            fc.Excerpt,
+		HTMLContent:        fc.HTMLContent,
+		WordCount:          fc.WordCount,
+		ReadingTimeMinutes: fc.ReadingTimeMinutes,
+		Language:           fc.Language,
+		Image:              fc.Image,
+		SiteName:           fc.SiteName,
+		Favicon:            fc.Favicon,
+		Tags:               fc.Tags,
+		Read:               fc.Read,
+		Starred:            fc.Starred,
 	}
-	return clips
 }
 
 func generateClipHash(clip *clip.Clip) string {
@@ -169,6 +504,23 @@ func generateClipHash(clip *clip.Clip) string {
 	return hex.EncodeToString(h.Sum(nil))
 }
 
+// isClipHash checks if the given string looks like a hash generated by generateClipHash.
+func isClipHash(s string) bool {
+	if len(s) != 2*sha256.Size {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
+func readJSON(data any, filename string) error {
+	jsonBytes, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(jsonBytes, data)
+}
+
 func writeJSON(data any, filename string) error {
 	jsonBytes, err := json.MarshalIndent(data, "", "  ")
 	if err != nil {
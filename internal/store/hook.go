@@ -0,0 +1,86 @@
+package store
+
+import (
+	"context"
+	"errors"
+
+	"github.com/timofurrer/influss/internal/clip"
+)
+
+// ChangeType is the kind of a change to a clip.
+type ChangeType string
+
+const (
+	ChangeCreated ChangeType = "created"
+	ChangeUpdated ChangeType = "updated"
+	ChangeDeleted ChangeType = "deleted"
+)
+
+// Change is a change to a clip made through the store.
+type Change struct {
+	Type ChangeType
+	// Clip is the stored clip or the deleted clip, which only has the URL set if it didn't exist.
+	Clip *clip.Clip
+}
+
+// ChangeHook is called after a clip was changed.
+type ChangeHook func(ctx context.Context, ch Change)
+
+// backend is the wrapped store, it's embedded with this name because the Store method would conflict with Store.
+type backend = Store
+
+// hookedStore calls the hooks for the changes made through it.
+type hookedStore struct {
+	backend
+	hooks []ChangeHook
+}
+
+// WithChangeHooks returns a store which calls the hooks after clips were created, updated or deleted through it.
+func WithChangeHooks(s Store, hooks ...ChangeHook) Store {
+	if len(hooks) == 0 {
+		return s
+	}
+	return &hookedStore{backend: s, hooks: hooks}
+}
+
+// Unwrap returns the wrapped store, e.g. to check for optional methods like RemoveOrphanedFiles.
+func (s *hookedStore) Unwrap() Store {
+	return s.backend
+}
+
+func (s *hookedStore) Store(ctx context.Context, c *clip.Clip) error {
+	_, err := s.backend.Get(ctx, c.URL)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	change := Change{Type: ChangeUpdated, Clip: c}
+	if err != nil {
+		change.Type = ChangeCreated
+	}
+
+	if err := s.backend.Store(ctx, c); err != nil {
+		return err
+	}
+	s.notify(ctx, change)
+	return nil
+}
+
+func (s *hookedStore) Delete(ctx context.Context, url string) error {
+	c, err := s.backend.Get(ctx, url)
+	if err != nil {
+		// the store decides how deleting a missing clip is handled
+		c = &clip.Clip{URL: url}
+	}
+
+	if err := s.backend.Delete(ctx, url); err != nil {
+		return err
+	}
+	s.notify(ctx, Change{Type: ChangeDeleted, Clip: c})
+	return nil
+}
+
+func (s *hookedStore) notify(ctx context.Context, ch Change) {
+	for _, hook := range s.hooks {
+		hook(ctx, ch)
+	}
+}
@@ -0,0 +1,143 @@
+package store
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/timofurrer/influss/internal/clip"
+)
+
+var (
+	_ Store = (*MemoryStore)(nil)
+)
+
+// MemoryStore keeps the clips in memory, they are lost when the process exits.
+// It's meant for tests and trying influss out.
+type MemoryStore struct {
+	createdAt time.Time
+
+	mu     sync.RWMutex
+	nextID int64
+	clips  map[string]*clip.Clip
+
+	events broadcaster
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{createdAt: time.Now(), clips: make(map[string]*clip.Clip)}
+}
+
+func (s *MemoryStore) CreatedAt() time.Time {
+	return s.createdAt
+}
+
+func (s *MemoryStore) Store(_ context.Context, c *clip.Clip) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored := copyClip(c)
+	event := Event{Type: ChangeCreated, URL: c.URL}
+	if existing, ok := s.clips[c.URL]; ok {
+		stored.ID = existing.ID
+		stored.ClippedAt = existing.ClippedAt
+		event.Type = ChangeUpdated
+	} else {
+		s.nextID++
+		stored.ID = s.nextID
+		stored.ClippedAt = cmp.Or(c.ClippedAt, time.Now())
+	}
+	s.clips[c.URL] = stored
+
+	c.ID = stored.ID
+	c.ClippedAt = stored.ClippedAt
+	s.events.publish(event)
+	return nil
+}
+
+func (s *MemoryStore) Load(_ context.Context, lastN int) []*clip.Clip {
+	clips, _, _ := s.List(context.Background(), Query{Limit: lastN})
+	return clips
+}
+
+func (s *MemoryStore) Get(_ context.Context, url string) (*clip.Clip, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	c, ok := s.clips[url]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return copyClip(c), nil
+}
+
+func (s *MemoryStore) GetByID(_ context.Context, id int64) (*clip.Clip, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, c := range s.clips {
+		if c.ID == id {
+			return copyClip(c), nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (s *MemoryStore) List(_ context.Context, q Query) ([]*clip.Clip, int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var clips []*clip.Clip
+	for _, c := range s.clips {
+		if q.matches(c) && q.matchesDetails(c) {
+			clips = append(clips, c)
+		}
+	}
+	slices.SortFunc(clips, func(a, b *clip.Clip) int {
+		if q.Ascending {
+			return cmp.Or(a.ClippedAt.Compare(b.ClippedAt), cmp.Compare(a.ID, b.ID))
+		}
+		return cmp.Or(b.ClippedAt.Compare(a.ClippedAt), cmp.Compare(b.ID, a.ID))
+	})
+
+	total := len(clips)
+	clips = clips[min(q.Offset, len(clips)):]
+	if q.Limit > 0 {
+		clips = clips[:min(q.Limit, len(clips))]
+	}
+
+	// like the other stores, listed clips come without their plain text and markdown content
+	listed := make([]*clip.Clip, 0, len(clips))
+	for _, c := range clips {
+		c = copyClip(c)
+		c.PlainTextContent = ""
+		c.MarkdownContent = ""
+		listed = append(listed, c)
+	}
+	return listed, total, nil
+}
+
+func (s *MemoryStore) Delete(_ context.Context, url string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.clips[url]; !ok {
+		return ErrNotFound
+	}
+	delete(s.clips, url)
+	s.events.publish(Event{Type: ChangeDeleted, URL: url})
+	return nil
+}
+
+func (s *MemoryStore) Subscribe(ctx context.Context) <-chan Event {
+	return s.events.subscribe(ctx)
+}
+
+// copyClip copies the clip, so that callers can't change the stored clips.
+func copyClip(c *clip.Clip) *clip.Clip {
+	copied := *c
+	copied.Tags = slices.Clone(c.Tags)
+	return &copied
+}
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS clip (
+    id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    url VARCHAR(2048) CHARACTER SET ascii COLLATE ascii_bin NOT NULL,
+    title TEXT,
+    author TEXT,
+    published_at DATETIME(6),
+    modified_at DATETIME(6),
+    excerpt TEXT,
+    html_content LONGTEXT,
+    plain_text_content LONGTEXT,
+    created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    updated_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6)
+) DEFAULT CHARACTER SET utf8mb4 COLLATE utf8mb4_bin;
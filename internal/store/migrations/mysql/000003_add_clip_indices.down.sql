@@ -0,0 +1,4 @@
+DROP INDEX idx_clips_url_unique ON clip;
+DROP INDEX idx_clips_created_at ON clip;
+DROP INDEX idx_clips_published_at ON clip;
+DROP INDEX idx_clips_url ON clip;
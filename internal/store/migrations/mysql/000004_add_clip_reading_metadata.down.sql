@@ -0,0 +1,4 @@
+DROP INDEX idx_clips_reading_time_minutes ON clip;
+ALTER TABLE clip DROP COLUMN language;
+ALTER TABLE clip DROP COLUMN reading_time_minutes;
+ALTER TABLE clip DROP COLUMN word_count;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS clip_tag;
+ALTER TABLE clip DROP COLUMN is_read;
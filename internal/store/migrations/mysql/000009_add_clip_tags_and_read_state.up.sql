@@ -0,0 +1,8 @@
+ALTER TABLE clip ADD COLUMN is_read BOOLEAN NOT NULL DEFAULT FALSE;
+CREATE TABLE IF NOT EXISTS clip_tag (
+    clip_url VARCHAR(2048) CHARACTER SET ascii COLLATE ascii_bin NOT NULL,
+    tag VARCHAR(255) NOT NULL,
+    PRIMARY KEY (clip_url, tag),
+    FOREIGN KEY (clip_url) REFERENCES clip(url) ON DELETE CASCADE
+) DEFAULT CHARACTER SET utf8mb4 COLLATE utf8mb4_bin;
+CREATE INDEX idx_clip_tags_tag ON clip_tag(tag);
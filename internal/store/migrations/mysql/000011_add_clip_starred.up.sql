@@ -0,0 +1 @@
+ALTER TABLE clip ADD COLUMN is_starred BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,4 @@
+ALTER TABLE clip ADD COLUMN word_count INTEGER;
+ALTER TABLE clip ADD COLUMN reading_time_minutes INTEGER;
+ALTER TABLE clip ADD COLUMN language TEXT;
+CREATE INDEX IF NOT EXISTS idx_clips_reading_time_minutes ON clip(reading_time_minutes);
@@ -0,0 +1,3 @@
+ALTER TABLE clip DROP COLUMN favicon;
+ALTER TABLE clip DROP COLUMN site_name;
+ALTER TABLE clip DROP COLUMN image;
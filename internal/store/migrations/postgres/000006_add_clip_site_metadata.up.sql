@@ -0,0 +1,3 @@
+ALTER TABLE clip ADD COLUMN image TEXT;
+ALTER TABLE clip ADD COLUMN site_name TEXT;
+ALTER TABLE clip ADD COLUMN favicon TEXT;
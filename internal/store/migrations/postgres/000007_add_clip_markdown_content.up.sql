@@ -0,0 +1 @@
+ALTER TABLE clip ADD COLUMN markdown_content TEXT;
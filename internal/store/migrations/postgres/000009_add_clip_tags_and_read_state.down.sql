@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_clip_tags_tag;
+DROP TABLE IF EXISTS clip_tag;
+ALTER TABLE clip DROP COLUMN is_read;
@@ -0,0 +1,7 @@
+ALTER TABLE clip ADD COLUMN is_read BOOLEAN NOT NULL DEFAULT FALSE;
+CREATE TABLE IF NOT EXISTS clip_tag (
+    clip_url TEXT NOT NULL REFERENCES clip(url) ON DELETE CASCADE,
+    tag TEXT NOT NULL,
+    PRIMARY KEY (clip_url, tag)
+);
+CREATE INDEX IF NOT EXISTS idx_clip_tags_tag ON clip_tag(tag);
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS clip (
+    id INTEGER PRIMARY KEY,
+    url TEXT NOT NULL,
+    title TEXT,
+    author TEXT,
+    published_at TIMESTAMP,
+    modified_at TIMESTAMP,
+    excerpt TEXT,
+    html_content TEXT,
+    plain_text_content TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
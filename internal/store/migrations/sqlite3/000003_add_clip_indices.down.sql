@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_clips_url_unique;
+DROP INDEX IF EXISTS idx_clips_created_at;
+DROP INDEX IF EXISTS idx_clips_published_at;
+DROP INDEX IF EXISTS idx_clips_url;
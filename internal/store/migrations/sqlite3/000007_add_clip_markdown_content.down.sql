@@ -0,0 +1 @@
+ALTER TABLE clip DROP COLUMN markdown_content;
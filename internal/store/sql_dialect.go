@@ -0,0 +1,81 @@
+package store
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// placeholderPattern matches the numbered placeholders of Postgres, like $1.
+var placeholderPattern = regexp.MustCompile(`\$\d+`)
+
+// rebind rewrites the placeholders of the query for the dialect of the driver.
+// The queries are written with the numbered placeholders of Postgres, which SQLite supports as well.
+// MySQL only supports positional placeholders, therefore each placeholder must be used once and in order.
+func rebind(driver, query string) string {
+	if driver != mysqlDriverName {
+		return query
+	}
+	return placeholderPattern.ReplaceAllString(query, "?")
+}
+
+// sqliteTimeFormats are the formats of the timestamps SQLite returns as text,
+// e.g. for columns not declared as TIMESTAMP or values of CURRENT_TIMESTAMP.
+var sqliteTimeFormats = []string{
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02T15:04:05.999999999-07:00",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02T15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// nullTime scans the timestamps of all drivers.
+// Postgres, MySQL and SQLite columns declared as TIMESTAMP are scanned as time.Time by their drivers,
+// other SQLite timestamps are parsed from text, which is assumed to be UTC without an offset.
+type nullTime struct {
+	Time  time.Time
+	Valid bool
+}
+
+func (t *nullTime) Scan(value any) error {
+	switch v := value.(type) {
+	case nil:
+		*t = nullTime{}
+		return nil
+	case time.Time:
+		*t = nullTime{Time: v, Valid: true}
+		return nil
+	case []byte:
+		return t.parse(string(v))
+	case string:
+		return t.parse(v)
+	default:
+		return fmt.Errorf("unsupported timestamp %v of type %T", value, value)
+	}
+}
+
+func (t *nullTime) parse(s string) error {
+	for _, format := range sqliteTimeFormats {
+		if parsed, err := time.Parse(format, s); err == nil {
+			*t = nullTime{Time: parsed, Valid: true}
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid timestamp %q", s)
+}
+
+// nullableTime stores zero times as NULL, MySQL doesn't support dates before the year 1000.
+func nullableTime(t time.Time) nullTime {
+	return nullTime{Time: t, Valid: !t.IsZero()}
+}
+
+func (t nullTime) Value() (driver.Value, error) {
+	if !t.Valid {
+		return nil, nil
+	}
+	return t.Time, nil
+}
@@ -2,142 +2,410 @@ package store
 
 import (
 	"context"
+	"crypto/sha256"
 	"database/sql"
 	"embed"
+	"encoding/hex"
 	"fmt"
 	"log/slog"
-	"path/filepath"
+	"math"
+	"path"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
+	"time"
+
+	"github.com/timofurrer/influss/internal/clip"
 )
 
-//go:embed migrations/*.sql
+// migrationsFS contains the migration files in a directory per dialect, named after the driver of the store.
+//
+//go:embed migrations/*/*.sql
 var migrationsFS embed.FS
 
 type migration struct {
 	version int64
 	name    string
 	sql     string
+	// down rolls the migration back, it's empty if there is nothing to roll back.
+	down string
+	// fn is run instead of sql for migrations that cannot be expressed in plain SQL.
+	fn func(ctx context.Context, tx *sql.Tx, driver string) error
+	// driver restricts the migration to a single driver, it's recorded without being run for other drivers.
+	driver string
+	// previousChecksums are the checksums of earlier versions of the SQL, which are still accepted as applied.
+	previousChecksums []string
+}
+
+// previousChecksums are the checksums of migration files which were rewritten after they were released, by driver and version.
+var previousChecksums = map[string]map[int64][]string{
+	sqlite3DriverName: {
+		// the clip table was created with the Postgres syntax, SQLite left the SERIAL id column empty
+		2: {"839cffb8fd45a6f50ceb73529e4a4da6e4974c9adaeb902ff289879b5df55167"},
+	},
+}
+
+// goMigrations are migrations implemented in Go, e.g. to backfill data derived from existing rows.
+// They are run for all dialects unless they are restricted to a driver.
+// The backfills have nothing to roll back, because the backfilled columns are dropped by rolling back their migrations.
+var goMigrations = []migration{
+	{version: 5, name: "backfill_clip_reading_metadata", fn: backfillClipReadingMetadata},
+	{version: 8, name: "backfill_clip_markdown_content", fn: backfillClipMarkdownContent},
+	// SQLite doesn't treat SERIAL as an alias for the rowid, therefore the id column of clip tables
+	// created with the first version of migration 2 stays empty. The current one uses an INTEGER PRIMARY KEY.
+	{version: 10, name: "populate_sqlite_clip_ids", driver: sqlite3DriverName, sql: `
+		UPDATE clip SET id = rowid WHERE id IS NULL;
+		CREATE TRIGGER IF NOT EXISTS clip_populate_id AFTER INSERT ON clip
+		FOR EACH ROW WHEN NEW.id IS NULL
+		BEGIN
+			UPDATE clip SET id = NEW.rowid WHERE rowid = NEW.rowid;
+		END;
+	`, down: `DROP TRIGGER IF EXISTS clip_populate_id;`},
+}
+
+// LatestMigration is the version to migrate to for applying all migrations.
+const LatestMigration int64 = math.MaxInt64
+
+// MigrationStatus is the state of a migration of the SQL store.
+type MigrationStatus struct {
+	Version int64
+	Name    string
+	// Applied is set if the migration was applied, at AppliedAt.
+	Applied   bool
+	AppliedAt time.Time
+	// Modified is set if the migration changed since it was applied.
+	Modified bool
+	// Unknown is set for applied migrations which this version of influss doesn't know, e.g. after a downgrade.
+	Unknown bool
+}
+
+// MigrationStep is a migration which is applied or rolled back.
+type MigrationStep struct {
+	Version int64
+	Name    string
+	Down    bool
+}
+
+// Migrations returns the state of the known and applied migrations ordered by version.
+func (s *SqlStore) Migrations(ctx context.Context) ([]MigrationStatus, error) {
+	return newMigrator(s.log, s.db, s.driver).status(ctx)
+}
+
+// Migrate applies the pending migrations up to the given version and rolls back the applied migrations after it.
+// With dryRun the steps are only returned.
+func (s *SqlStore) Migrate(ctx context.Context, version int64, dryRun bool) ([]MigrationStep, error) {
+	return newMigrator(s.log, s.db, s.driver).migrate(ctx, version, dryRun)
+}
+
+// MigrateDown rolls back the latest applied migration.
+// With dryRun the step is only returned.
+func (s *SqlStore) MigrateDown(ctx context.Context, dryRun bool) ([]MigrationStep, error) {
+	m := newMigrator(s.log, s.db, s.driver)
+	statuses, err := m.status(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var applied []int64
+	for _, st := range statuses {
+		if st.Applied {
+			applied = append(applied, st.Version)
+		}
+	}
+	switch len(applied) {
+	case 0:
+		return nil, nil
+	case 1:
+		return m.migrate(ctx, 0, dryRun)
+	default:
+		return m.migrate(ctx, applied[len(applied)-2], dryRun)
+	}
 }
 
 type migrator struct {
-	log *slog.Logger
-	db  *sql.DB
+	log    *slog.Logger
+	db     *sql.DB
+	driver string
 }
 
-func newMigrator(log *slog.Logger, db *sql.DB) *migrator {
-	return &migrator{log: log, db: db}
+func newMigrator(log *slog.Logger, db *sql.DB, driver string) *migrator {
+	return &migrator{log: log, db: db, driver: driver}
 }
 
+// run applies all pending migrations.
 func (m *migrator) run(ctx context.Context) error {
+	_, err := m.migrate(ctx, LatestMigration, false)
+	return err
+}
+
+// appliedMigration is a row of the schema_migration table.
+type appliedMigration struct {
+	appliedAt time.Time
+	// checksum is empty for migrations applied before checksums were recorded.
+	checksum string
+}
+
+func (m *migrator) status(ctx context.Context) ([]MigrationStatus, error) {
+	migrations, applied, err := m.state(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var statuses []MigrationStatus
+	for _, migration := range migrations {
+		st := MigrationStatus{Version: migration.version, Name: migration.name}
+		if a, ok := applied[migration.version]; ok {
+			st.Applied = true
+			st.AppliedAt = a.appliedAt
+			st.Modified = migration.modified(a.checksum)
+			delete(applied, migration.version)
+		}
+		statuses = append(statuses, st)
+	}
+	for version, a := range applied {
+		statuses = append(statuses, MigrationStatus{Version: version, Applied: true, AppliedAt: a.appliedAt, Unknown: true})
+	}
+	slices.SortFunc(statuses, func(a, b MigrationStatus) int { return int(a.Version - b.Version) })
+	return statuses, nil
+}
+
+func (m *migrator) migrate(ctx context.Context, target int64, dryRun bool) ([]MigrationStep, error) {
+	migrations, applied, err := m.state(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	known := make(map[int64]bool)
+	for _, migration := range migrations {
+		known[migration.version] = true
+		a, ok := applied[migration.version]
+		if ok && migration.modified(a.checksum) {
+			return nil, fmt.Errorf("migration %d %s was modified after it was applied", migration.version, migration.name)
+		}
+	}
+	for version := range applied {
+		if !known[version] && version > target {
+			return nil, fmt.Errorf("migration %d is unknown to this version of influss and can't be rolled back", version)
+		}
+	}
+
+	// roll back the newest migrations first, then apply the oldest first
+	var steps []MigrationStep
+	var pending []migration
+	for i := len(migrations) - 1; i >= 0; i-- {
+		if _, ok := applied[migrations[i].version]; ok && migrations[i].version > target {
+			steps = append(steps, MigrationStep{Version: migrations[i].version, Name: migrations[i].name, Down: true})
+			pending = append(pending, migrations[i])
+		}
+	}
+	for _, migration := range migrations {
+		if _, ok := applied[migration.version]; !ok && migration.version <= target {
+			steps = append(steps, MigrationStep{Version: migration.version, Name: migration.name})
+			pending = append(pending, migration)
+		}
+	}
+	if dryRun {
+		return steps, nil
+	}
+
+	if err := m.recordChecksums(ctx, migrations, applied); err != nil {
+		return nil, fmt.Errorf("failed to record migration checksums: %w", err)
+	}
+	for i, migration := range pending {
+		if steps[i].Down {
+			err = m.rollback(ctx, migration)
+		} else {
+			err = m.apply(ctx, migration)
+		}
+		if err != nil {
+			return steps[:i], err
+		}
+	}
+	return steps, nil
+}
+
+// state returns the known migrations ordered by version and the applied migrations.
+func (m *migrator) state(ctx context.Context) ([]migration, map[int64]appliedMigration, error) {
 	if err := m.initialize(ctx); err != nil {
-		return fmt.Errorf("failed to initialize migrations table: %w", err)
+		return nil, nil, fmt.Errorf("failed to initialize migrations table: %w", err)
 	}
 
 	applied, err := m.getAppliedMigrations(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to get applied migrations: %w", err)
+		return nil, nil, fmt.Errorf("failed to get applied migrations: %w", err)
 	}
 
-	migrations, err := loadMigrations()
+	migrations, err := loadMigrations(m.driver)
 	if err != nil {
-		return fmt.Errorf("failed to load migrations: %w", err)
+		return nil, nil, fmt.Errorf("failed to load migrations: %w", err)
 	}
+	return migrations, applied, nil
+}
 
-	// Apply pending migrations in a transaction
+// recordChecksums records the checksums of the migrations applied before checksums were recorded.
+func (m *migrator) recordChecksums(ctx context.Context, migrations []migration, applied map[int64]appliedMigration) error {
 	for _, migration := range migrations {
-		if !applied[migration.version] {
-			// Start transaction for each migration
-			tx, err := m.db.BeginTx(ctx, nil)
-			if err != nil {
-				return fmt.Errorf("failed to start transaction: %w", err)
-			}
+		a, ok := applied[migration.version]
+		if !ok || a.checksum != "" || migration.checksum() == "" {
+			continue
+		}
+		if _, err := m.db.ExecContext(ctx, rebind(m.driver, "UPDATE schema_migration SET checksum = $1 WHERE version = $2"), migration.checksum(), migration.version); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-			// Execute migration
-			if _, err := tx.ExecContext(ctx, migration.sql); err != nil {
-				tx.Rollback()
-				return fmt.Errorf("failed to apply migration %d: %w", migration.version, err)
-			}
+// apply applies the migration in a transaction.
+// MySQL commits schema changes implicitly, a failed migration may therefore be partially applied.
+func (m *migrator) apply(ctx context.Context, migration migration) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
 
-			// Record migration
-			if _, err := tx.ExecContext(ctx,
-				"INSERT INTO schema_migration (version) VALUES ($1)",
-				migration.version,
-			); err != nil {
-				tx.Rollback()
-				return fmt.Errorf("failed to record migration %d: %w", migration.version, err)
-			}
+	if migration.driver != "" && migration.driver != m.driver {
+		m.log.Info("Skipping migration for other driver", slog.Int64("version", migration.version), slog.String("name", migration.name))
+	} else if err := migration.up(ctx, tx, m.driver); err != nil {
+		return fmt.Errorf("failed to apply migration %d: %w", migration.version, err)
+	}
+
+	checksum := sql.NullString{String: migration.checksum(), Valid: migration.checksum() != ""}
+	if _, err := tx.ExecContext(ctx,
+		rebind(m.driver, "INSERT INTO schema_migration (version, applied_at, checksum) VALUES ($1, $2, $3)"),
+		migration.version, time.Now().UTC(), checksum,
+	); err != nil {
+		return fmt.Errorf("failed to record migration %d: %w", migration.version, err)
+	}
 
-			// Commit transaction
-			if err := tx.Commit(); err != nil {
-				return fmt.Errorf("failed to commit migration %d: %w", migration.version, err)
-			}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit migration %d: %w", migration.version, err)
+	}
+	m.log.Info("Applied migration", slog.Int64("version", migration.version), slog.String("name", migration.name))
+	return nil
+}
+
+// rollback rolls the migration back in a transaction.
+func (m *migrator) rollback(ctx context.Context, migration migration) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
 
-			m.log.Info("Applied migration", slog.Int64("version", migration.version), slog.String("name", migration.name))
+	if (migration.driver == "" || migration.driver == m.driver) && migration.down != "" {
+		if _, err := tx.ExecContext(ctx, migration.down); err != nil {
+			return fmt.Errorf("failed to roll back migration %d: %w", migration.version, err)
 		}
 	}
+	if _, err := tx.ExecContext(ctx, rebind(m.driver, "DELETE FROM schema_migration WHERE version = $1"), migration.version); err != nil {
+		return fmt.Errorf("failed to remove migration %d: %w", migration.version, err)
+	}
 
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit rollback of migration %d: %w", migration.version, err)
+	}
+	m.log.Info("Rolled back migration", slog.Int64("version", migration.version), slog.String("name", migration.name))
 	return nil
 }
 
 func (m *migrator) initialize(ctx context.Context) error {
+	appliedAt := "TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP"
+	if m.driver == mysqlDriverName {
+		appliedAt = "DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6)"
+	}
 	query := `
 		CREATE TABLE IF NOT EXISTS schema_migration (
 			version BIGINT PRIMARY KEY,
-			applied_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+			applied_at ` + appliedAt + `,
+			checksum TEXT
 		)
 	`
+	if _, err := m.db.ExecContext(ctx, query); err != nil {
+		return err
+	}
 
-	_, err := m.db.ExecContext(ctx, query)
-	return err
+	// the table of older versions doesn't have the checksum column yet
+	if _, err := m.db.ExecContext(ctx, "SELECT checksum FROM schema_migration LIMIT 1"); err != nil {
+		if _, err := m.db.ExecContext(ctx, "ALTER TABLE schema_migration ADD COLUMN checksum TEXT"); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-func (m *migrator) getAppliedMigrations(ctx context.Context) (map[int64]bool, error) {
-	rows, err := m.db.QueryContext(ctx, "SELECT version FROM schema_migration ORDER BY version ASC")
+func (m *migrator) getAppliedMigrations(ctx context.Context) (map[int64]appliedMigration, error) {
+	rows, err := m.db.QueryContext(ctx, "SELECT version, applied_at, checksum FROM schema_migration ORDER BY version ASC")
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	applied := make(map[int64]bool)
+	applied := make(map[int64]appliedMigration)
 	for rows.Next() {
 		var version int64
-		if err := rows.Scan(&version); err != nil {
+		var appliedAt nullTime
+		var checksum sql.NullString
+		if err := rows.Scan(&version, &appliedAt, &checksum); err != nil {
 			return nil, err
 		}
-		applied[version] = true
+		applied[version] = appliedMigration{appliedAt: appliedAt.Time, checksum: checksum.String}
 	}
 	return applied, rows.Err()
 }
 
-func loadMigrations() ([]migration, error) {
-	entries, err := migrationsFS.ReadDir("migrations")
+// loadMigrations loads the migrations of the driver's dialect from the paired NNNNNN_name.up.sql
+// and NNNNNN_name.down.sql files and adds the Go migrations, ordered by version.
+func loadMigrations(driver string) ([]migration, error) {
+	dir := path.Join("migrations", driver)
+	entries, err := migrationsFS.ReadDir(dir)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read embedded migrations directory: %w", err)
 	}
 
-	var migrations []migration
+	byVersion := make(map[int64]*migration)
+	hasDown := make(map[int64]bool)
 	for _, entry := range entries {
-		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".sql") {
-			version, name, err := parseMigrationFileName(entry.Name())
-			if err != nil {
-				return nil, err
-			}
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".sql") {
+			continue
+		}
+		version, name, down, err := parseMigrationFileName(entry.Name())
+		if err != nil {
+			return nil, err
+		}
 
-			content, err := migrationsFS.ReadFile(filepath.Join("migrations", entry.Name()))
-			if err != nil {
-				return nil, fmt.Errorf("failed to read migration %s: %w", entry.Name(), err)
-			}
+		content, err := migrationsFS.ReadFile(path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", entry.Name(), err)
+		}
 
-			migrations = append(migrations, migration{
-				version: version,
-				name:    name,
-				sql:     string(content),
-			})
+		m, ok := byVersion[version]
+		if !ok {
+			m = &migration{version: version, name: name, previousChecksums: previousChecksums[driver][version]}
+			byVersion[version] = m
+		}
+		if m.name != name {
+			return nil, fmt.Errorf("migration %d has up and down files with different names", version)
+		}
+		if down {
+			m.down = string(content)
+			hasDown[version] = true
+		} else {
+			m.sql = string(content)
 		}
 	}
 
+	var migrations []migration
+	for version, m := range byVersion {
+		if m.sql == "" || !hasDown[version] {
+			return nil, fmt.Errorf("migration %d %s must have both an up and a down file", version, m.name)
+		}
+		migrations = append(migrations, *m)
+	}
+
+	migrations = append(migrations, goMigrations...)
+
 	// Sort migrations by version
 	sort.Slice(migrations, func(i, j int) bool {
 		return migrations[i].version < migrations[j].version
@@ -146,18 +414,116 @@ func loadMigrations() ([]migration, error) {
 	return migrations, nil
 }
 
-// parseMigrationFileName extracts version and name from filename like "000001_create_table.sql"
-func parseMigrationFileName(filename string) (int64, string, error) {
+func (m migration) up(ctx context.Context, tx *sql.Tx, driver string) error {
+	if m.fn != nil {
+		return m.fn(ctx, tx, driver)
+	}
+	_, err := tx.ExecContext(ctx, m.sql)
+	return err
+}
+
+// checksum identifies the SQL of the migration to detect changes after it was applied.
+// Migrations implemented in Go have no checksum.
+func (m migration) checksum() string {
+	if m.fn != nil {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(m.sql))
+	return hex.EncodeToString(sum[:])
+}
+
+// modified checks if the migration changed since it was applied with the given checksum.
+func (m migration) modified(checksum string) bool {
+	return checksum != "" && checksum != m.checksum() && !slices.Contains(m.previousChecksums, checksum)
+}
+
+// parseMigrationFileName extracts version, name and direction from filenames like "000001_create_table.up.sql"
+func parseMigrationFileName(filename string) (int64, string, bool, error) {
 	parts := strings.SplitN(filename, "_", 2)
 	if len(parts) != 2 {
-		return 0, "", fmt.Errorf("invalid migration filename format: %s", filename)
+		return 0, "", false, fmt.Errorf("invalid migration filename format: %s", filename)
 	}
 
 	version, err := strconv.ParseInt(parts[0], 10, 64)
 	if err != nil {
-		return 0, "", fmt.Errorf("invalid migration version: %s", parts[0])
+		return 0, "", false, fmt.Errorf("invalid migration version: %s", parts[0])
 	}
 
 	name := strings.TrimSuffix(parts[1], ".sql")
-	return version, name, nil
+	switch {
+	case strings.HasSuffix(name, ".up"):
+		return version, strings.TrimSuffix(name, ".up"), false, nil
+	case strings.HasSuffix(name, ".down"):
+		return version, strings.TrimSuffix(name, ".down"), true, nil
+	default:
+		return 0, "", false, fmt.Errorf("invalid migration filename format, must end with .up.sql or .down.sql: %s", filename)
+	}
+}
+
+func backfillClipReadingMetadata(ctx context.Context, tx *sql.Tx, driver string) error {
+	// NOTE: rows are identified by their URL, because the id column isn't populated by SQLite
+	rows, err := tx.QueryContext(ctx, "SELECT url, plain_text_content FROM clip WHERE word_count IS NULL")
+	if err != nil {
+		return err
+	}
+
+	metadata := make(map[string]clip.Metadata)
+	for rows.Next() {
+		var url string
+		var text sql.NullString
+		if err := rows.Scan(&url, &text); err != nil {
+			rows.Close()
+			return err
+		}
+		metadata[url] = clip.ComputeMetadata(text.String)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for url, m := range metadata {
+		_, err := tx.ExecContext(ctx,
+			rebind(driver, "UPDATE clip SET word_count = $1, reading_time_minutes = $2, language = COALESCE(language, $3) WHERE url = $4"),
+			m.WordCount, m.ReadingTimeMinutes, m.Language, url,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func backfillClipMarkdownContent(ctx context.Context, tx *sql.Tx, driver string) error {
+	rows, err := tx.QueryContext(ctx, "SELECT url, html_content FROM clip WHERE markdown_content IS NULL")
+	if err != nil {
+		return err
+	}
+
+	markdown := make(map[string]string)
+	for rows.Next() {
+		var url string
+		var content sql.NullString
+		if err := rows.Scan(&url, &content); err != nil {
+			rows.Close()
+			return err
+		}
+		md, err := clip.HTMLToMarkdown(content.String)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		markdown[url] = md
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for url, md := range markdown {
+		if _, err := tx.ExecContext(ctx, rebind(driver, "UPDATE clip SET markdown_content = $1 WHERE url = $2"), md, url); err != nil {
+			return err
+		}
+	}
+	return nil
 }
@@ -1,15 +1,24 @@
 package store
 
 import (
+	"cmp"
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
+	"math"
 	"net/url"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
-	_ "github.com/lib/pq"
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
 	_ "github.com/mattn/go-sqlite3"
+	_ "modernc.org/sqlite"
 
 	"github.com/timofurrer/influss/internal/clip"
 )
@@ -21,46 +30,156 @@ var (
 const (
 	sqlite3DriverName  = "sqlite3"
 	postgresDriverName = "postgres"
+	mysqlDriverName    = "mysql"
+	// moderncSqliteDriverName is the pure-Go SQLite driver, which doesn't need cgo.
+	// It shares the SQL dialect and therefore the driver of the store with sqlite3.
+	moderncSqliteDriverName = "sqlite"
+)
+
+const (
+	// clipEventsChannel is the Postgres notification channel of the clip events.
+	clipEventsChannel = "influss_clip_events"
+	// maxNotifyPayload is the maximum size of a Postgres notification payload.
+	maxNotifyPayload = 8000
 )
 
 type SqlStore struct {
+	log    *slog.Logger
 	driver string
+	dsn    string
 	db     *sql.DB
+
+	events     broadcaster
+	listenOnce sync.Once
+}
+
+// SqlConfig configures the SQL store.
+type SqlConfig struct {
+	// ConnectionString is a sqlite3://, sqlite://, postgres:// or mysql:// URL.
+	ConnectionString string
+	// MaxOpenConns, MaxIdleConns and ConnMaxLifetime configure the connection pool,
+	// zero values keep the defaults of database/sql.
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	// ConnectTimeout is how long connecting is retried, e.g. while the database is still booting.
+	ConnectTimeout time.Duration
+	// NoAutoMigrate disables applying the pending migrations when the store is created,
+	// e.g. to apply them with the migrate command during a maintenance window.
+	// Pending and modified migrations are logged instead.
+	NoAutoMigrate bool
+	// SkipMigrations neither applies nor checks the migrations when the store is created,
+	// for the migrate command which manages them itself.
+	SkipMigrations bool
 }
 
-func NewSqlStore(log *slog.Logger, connectionString string) (*SqlStore, error) {
-	u, err := url.Parse(connectionString)
+func NewSqlStore(log *slog.Logger, cfg SqlConfig) (*SqlStore, error) {
+	u, err := url.Parse(cfg.ConnectionString)
 	if err != nil {
 		return nil, fmt.Errorf("sql connection string must be valid URL: %w", err)
 	}
 
+	// the driver of the store is the SQL dialect, the database is opened with the driver of the scheme
 	var driver, dsn string
+	openDriver := u.Scheme
 	switch u.Scheme {
-	case sqlite3DriverName:
+	case sqlite3DriverName, moderncSqliteDriverName:
 		driver = sqlite3DriverName
-		dsn = u.Host
-	case postgresDriverName:
+		d, err := parseSqliteDSN(u)
+		if err != nil {
+			return nil, err
+		}
+		dsn = d.mattn()
+		if u.Scheme == moderncSqliteDriverName {
+			dsn = d.modernc()
+		}
+	case postgresDriverName, "postgresql":
 		driver = postgresDriverName
-		dsn = connectionString
+		openDriver = postgresDriverName
+		dsn = cfg.ConnectionString
+	case mysqlDriverName, "mariadb":
+		driver = mysqlDriverName
+		openDriver = mysqlDriverName
+		if dsn, err = mysqlDSN(u); err != nil {
+			return nil, err
+		}
 	default:
-		return nil, fmt.Errorf("sql connection string must either have sqlite3:// or postgres:// scheme")
+		return nil, fmt.Errorf("sql connection string must either have sqlite3://, sqlite://, postgres:// or mysql:// scheme")
 	}
 
-	db, err := sql.Open(driver, dsn)
+	db, err := sql.Open(openDriver, dsn)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open sql database: %w", err)
 	}
+	if cfg.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(cfg.MaxOpenConns)
+	}
+	if cfg.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(cfg.MaxIdleConns)
+	}
+	if cfg.ConnMaxLifetime > 0 {
+		db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	}
 
-	migrator := newMigrator(log, db)
-	if err := migrator.run(context.Background()); err != nil {
-		return nil, fmt.Errorf("failed to run migrations: %w", err)
+	if err := ping(context.Background(), log, db, cfg.ConnectTimeout); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to connect to sql database: %w", err)
 	}
 
-	return &SqlStore{driver: driver, db: db}, nil
+	migrator := newMigrator(log, db, driver)
+	switch {
+	case cfg.SkipMigrations:
+	case cfg.NoAutoMigrate:
+		statuses, err := migrator.status(context.Background())
+		if err != nil {
+			return nil, fmt.Errorf("failed to check migrations: %w", err)
+		}
+		pending := 0
+		for _, st := range statuses {
+			if !st.Applied {
+				pending++
+			}
+			if st.Modified {
+				log.Warn("Migration was modified after it was applied", slog.Int64("version", st.Version), slog.String("name", st.Name))
+			}
+		}
+		if pending > 0 {
+			log.Warn("There are pending migrations, apply them with the migrate command", slog.Int("pending", pending))
+		}
+	default:
+		if err := migrator.run(context.Background()); err != nil {
+			return nil, fmt.Errorf("failed to run migrations: %w", err)
+		}
+	}
+
+	return &SqlStore{log: log, driver: driver, dsn: dsn, db: db}, nil
+}
+
+// ping pings the database until it's available or the timeout is over, with a single attempt for a zero timeout.
+func ping(ctx context.Context, log *slog.Logger, db *sql.DB, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	backoff := 500 * time.Millisecond
+	for {
+		err := db.PingContext(ctx)
+		if err == nil {
+			return nil
+		}
+		if time.Now().Add(backoff).After(deadline) {
+			return err
+		}
+
+		log.Warn("Database is not available yet, retrying", slog.String("error", err.Error()), slog.Duration("backoff", backoff))
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, 5*time.Second)
+	}
 }
 
 func (s *SqlStore) CreatedAt() time.Time {
-	var createdAt sql.NullString
+	var createdAt nullTime
 	err := s.db.QueryRow(`
 		SELECT applied_at
 		FROM schema_migration
@@ -72,20 +191,17 @@ func (s *SqlStore) CreatedAt() time.Time {
 		return time.Time{}
 	}
 
-	return s.parseTime(createdAt)
+	return createdAt.Time
 }
 
 func (s *SqlStore) Load(ctx context.Context, lastN int) []*clip.Clip {
 	query := `
-		SELECT
-			url, title, author,
-			published_at, modified_at,
-			excerpt, html_content
+		SELECT ` + clipColumns + `
 		FROM clip
 		ORDER BY created_at DESC
 		LIMIT $1`
 
-	rows, err := s.db.QueryContext(ctx, query, lastN)
+	rows, err := s.db.QueryContext(ctx, s.rebind(query), lastN)
 	if err != nil {
 		return nil
 	}
@@ -93,81 +209,472 @@ func (s *SqlStore) Load(ctx context.Context, lastN int) []*clip.Clip {
 
 	var clips []*clip.Clip
 	for rows.Next() {
-		c := &clip.Clip{}
-
-		// Use temporary variables for timestamp fields
-		var publishedAt, modifiedAt sql.NullString
-
-		err := rows.Scan(
-			&c.URL,
-			&c.Title,
-			&c.Author,
-			&publishedAt,
-			&modifiedAt,
-			&c.Excerpt,
-			&c.HTMLContent,
-		)
+		c, err := s.scanClip(rows)
 		if err != nil {
 			continue // Skip failed rows but continue processing
 		}
 
-		// Parse the timestamps
-		c.PublishedAt = s.parseTime(publishedAt)
-		c.ModifiedAt = s.parseTime(modifiedAt)
-
 		clips = append(clips, c)
 	}
+	rows.Close()
 
 	// If we hit an error during iteration, return what we have so far
 	if rows.Err() != nil {
 		return clips
 	}
 
+	if err := s.loadTags(ctx, clips); err != nil {
+		return clips
+	}
+
 	return clips
 }
 
-func (s *SqlStore) Store(ctx context.Context, clip *clip.Clip) error {
+func (s *SqlStore) Get(ctx context.Context, url string) (*clip.Clip, error) {
+	return s.get(ctx, "url = $1", url)
+}
+
+func (s *SqlStore) GetByID(ctx context.Context, id int64) (*clip.Clip, error) {
+	return s.get(ctx, "id = $1", id)
+}
+
+// get returns the single clip matching the given condition with its content.
+func (s *SqlStore) get(ctx context.Context, condition string, arg any) (*clip.Clip, error) {
 	query := `
-		INSERT INTO clip (
-			url, title, author,
-			published_at, modified_at,
-			excerpt, html_content, plain_text_content
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-		ON CONFLICT (url) DO UPDATE SET
-			title = EXCLUDED.title,
-			author = EXCLUDED.author,
-			published_at = EXCLUDED.published_at,
-			modified_at = EXCLUDED.modified_at,
-			excerpt = EXCLUDED.excerpt,
-			html_content = EXCLUDED.html_content,
-			plain_text_content = EXCLUDED.plain_text_content,
-			updated_at = CURRENT_TIMESTAMP
-	`
-
-	_, err := s.db.ExecContext(
+		SELECT ` + clipColumns + `, plain_text_content, markdown_content
+		FROM clip
+		WHERE ` + condition
+
+	var plainTextContent, markdownContent sql.NullString
+	c, err := s.scanClip(s.db.QueryRowContext(ctx, s.rebind(query), arg), &plainTextContent, &markdownContent)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c.PlainTextContent = plainTextContent.String
+	c.MarkdownContent = markdownContent.String
+
+	if err := s.loadTags(ctx, []*clip.Clip{c}); err != nil {
+		return nil, fmt.Errorf("failed to load tags: %w", err)
+	}
+	return c, nil
+}
+
+func (s *SqlStore) List(ctx context.Context, q Query) ([]*clip.Clip, int, error) {
+	where, args := q.sqlWhere(s.driver)
+
+	var total int
+	if err := s.db.QueryRowContext(ctx, s.rebind("SELECT COUNT(*) FROM clip"+where), args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count clips: %w", err)
+	}
+
+	order := "DESC"
+	if q.Ascending {
+		order = "ASC"
+	}
+	limit := q.Limit
+	if limit <= 0 {
+		limit = math.MaxInt32
+	}
+	args = append(args, limit, q.Offset)
+	query := `
+		SELECT ` + clipColumns + `
+		FROM clip` + where + `
+		ORDER BY created_at ` + order + `, id ` + order + `
+		LIMIT $` + strconv.Itoa(len(args)-1) + ` OFFSET $` + strconv.Itoa(len(args))
+
+	rows, err := s.db.QueryContext(ctx, s.rebind(query), args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list clips: %w", err)
+	}
+	defer rows.Close()
+
+	var clips []*clip.Clip
+	for rows.Next() {
+		c, err := s.scanClip(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan clip: %w", err)
+		}
+		clips = append(clips, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to list clips: %w", err)
+	}
+	rows.Close()
+
+	if err := s.loadTags(ctx, clips); err != nil {
+		return nil, 0, fmt.Errorf("failed to load tags: %w", err)
+	}
+	return clips, total, nil
+}
+
+func (s *SqlStore) Delete(ctx context.Context, url string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, s.rebind("DELETE FROM clip_tag WHERE clip_url = $1"), url); err != nil {
+		return fmt.Errorf("failed to delete tags: %w", err)
+	}
+	res, err := tx.ExecContext(ctx, s.rebind("DELETE FROM clip WHERE url = $1"), url)
+	if err != nil {
+		return fmt.Errorf("failed to delete clip: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return s.commit(ctx, tx, Event{Type: ChangeDeleted, URL: url})
+}
+
+// commit commits the transaction and publishes the event of the change made in it.
+// Postgres notifies all instances sharing the database, including this one,
+// otherwise, e.g. for SQLite and MySQL, the event is published within the process.
+func (s *SqlStore) commit(ctx context.Context, tx *sql.Tx, e Event) error {
+	local := s.driver != postgresDriverName
+	if !local {
+		payload, err := json.Marshal(e)
+		if err != nil {
+			return fmt.Errorf("failed to encode clip event: %w", err)
+		}
+		if len(payload) < maxNotifyPayload {
+			if _, err := tx.ExecContext(ctx, "SELECT pg_notify($1, $2)", clipEventsChannel, string(payload)); err != nil {
+				return fmt.Errorf("failed to notify about clip event: %w", err)
+			}
+		} else {
+			// other instances miss the events of clips with exceptionally long URLs
+			local = true
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	if local {
+		s.events.publish(e)
+	}
+	return nil
+}
+
+func (s *SqlStore) Subscribe(ctx context.Context) <-chan Event {
+	if s.driver == postgresDriverName {
+		s.listenOnce.Do(s.listen)
+	}
+	return s.events.subscribe(ctx)
+}
+
+// listen publishes the events notified by Postgres within the process.
+// The listener reconnects on its own, events notified while it's disconnected are missed.
+func (s *SqlStore) listen() {
+	listener := pq.NewListener(s.dsn, time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			s.log.Warn("Postgres listener connection failed", slog.String("error", err.Error()))
+		}
+	})
+	if err := listener.Listen(clipEventsChannel); err != nil {
+		s.log.Error("Failed to listen for clip events", slog.String("error", err.Error()))
+	}
+
+	go func() {
+		for n := range listener.Notify {
+			if n == nil {
+				s.log.Warn("Postgres listener reconnected, clip events may have been missed")
+				continue
+			}
+			var e Event
+			if err := json.Unmarshal([]byte(n.Extra), &e); err != nil {
+				s.log.Warn("Failed to decode clip event", slog.String("error", err.Error()))
+				continue
+			}
+			s.events.publish(e)
+		}
+	}()
+}
+
+// likeEscaper escapes the wildcards of LIKE patterns with the escape character of likeEscape.
+// It isn't a backslash, which MySQL treats as escape character in string literals.
+var likeEscaper = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+
+const likeEscape = ` ESCAPE '!'`
+
+// sqlWhere returns the WHERE clause for the filters of the query and its arguments.
+// Each placeholder is used once, so that the clause can be rebound for MySQL.
+func (q Query) sqlWhere(driver string) (string, []any) {
+	var conditions []string
+	var args []any
+	arg := func(v any) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+
+	if q.Read != nil {
+		conditions = append(conditions, "is_read = "+arg(*q.Read))
+	}
+	if q.Starred != nil {
+		conditions = append(conditions, "is_starred = "+arg(*q.Starred))
+	}
+	if !q.Since.IsZero() {
+		conditions = append(conditions, "created_at > "+arg(q.Since.UTC()))
+	}
+	if !q.Until.IsZero() {
+		conditions = append(conditions, "created_at < "+arg(q.Until.UTC()))
+	}
+	// the URLs are ASCII in MySQL, they are converted for comparisons with Unicode patterns
+	urlColumn := "url"
+	if driver == mysqlDriverName {
+		urlColumn = "CONVERT(url USING utf8mb4)"
+	}
+	if q.Domain != "" {
+		// matches the host of URLs like scheme://[user@]host[:port][/path]
+		domain := likeEscaper.Replace(strings.ToLower(q.Domain))
+		var hosts []string
+		for _, host := range []string{domain, "%." + domain} {
+			for _, rest := range []string{"", "/%", ":%", "?%", "#%"} {
+				hosts = append(hosts, "LOWER("+urlColumn+") LIKE "+arg("%://"+host+rest)+likeEscape)
+			}
+		}
+		conditions = append(conditions, "("+strings.Join(hosts, " OR ")+")")
+	}
+	if q.MaxReadingMinutes > 0 {
+		conditions = append(conditions, "(reading_time_minutes IS NULL OR reading_time_minutes <= "+arg(q.MaxReadingMinutes)+")")
+	}
+	for _, t := range q.Tags {
+		conditions = append(conditions, "url IN (SELECT clip_url FROM clip_tag WHERE tag = "+arg(t)+")")
+	}
+	if q.Search != "" {
+		pattern := "%" + likeEscaper.Replace(strings.ToLower(q.Search)) + "%"
+		var fields []string
+		for _, column := range []string{"title", "author", urlColumn, "excerpt", "site_name"} {
+			fields = append(fields, "LOWER("+column+") LIKE "+arg(pattern)+likeEscape)
+		}
+		conditions = append(conditions, "("+strings.Join(fields, " OR ")+")")
+	}
+
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// upsertedClipColumns are the columns set by Store, the URL and creation time are not updated.
+var upsertedClipColumns = []string{
+	"url", "title", "author",
+	"published_at", "modified_at",
+	"excerpt", "html_content", "plain_text_content",
+	"word_count", "reading_time_minutes", "language",
+	"image", "site_name", "favicon",
+	"markdown_content",
+	"is_read", "is_starred", "created_at",
+}
+
+// upsertClipQuery returns the query inserting a clip or updating all but its URL and creation time.
+func upsertClipQuery(driver string) string {
+	placeholders := make([]string, len(upsertedClipColumns))
+	for i := range upsertedClipColumns {
+		placeholders[i] = "$" + strconv.Itoa(i+1)
+	}
+
+	// all but the first and last column, url and created_at
+	updated := upsertedClipColumns[1 : len(upsertedClipColumns)-1]
+	assignments := make([]string, 0, len(updated)+1)
+	for _, column := range updated {
+		if driver == mysqlDriverName {
+			assignments = append(assignments, column+" = VALUES("+column+")")
+		} else {
+			assignments = append(assignments, column+" = EXCLUDED."+column)
+		}
+	}
+	assignments = append(assignments, "updated_at = CURRENT_TIMESTAMP")
+
+	conflict := "ON CONFLICT (url) DO UPDATE SET"
+	if driver == mysqlDriverName {
+		conflict = "ON DUPLICATE KEY UPDATE"
+	}
+	return "INSERT INTO clip (" + strings.Join(upsertedClipColumns, ", ") + ") VALUES (" + strings.Join(placeholders, ", ") + ") " +
+		conflict + " " + strings.Join(assignments, ", ")
+}
+
+func (s *SqlStore) Store(ctx context.Context, clip *clip.Clip) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	event := Event{Type: ChangeCreated, URL: clip.URL}
+	var exists bool
+	if err := tx.QueryRowContext(ctx, s.rebind("SELECT EXISTS (SELECT 1 FROM clip WHERE url = $1)"), clip.URL).Scan(&exists); err != nil {
+		return fmt.Errorf("failed to check for existing clip: %w", err)
+	}
+	if exists {
+		event.Type = ChangeUpdated
+	}
+
+	_, err = tx.ExecContext(
 		ctx,
-		query,
+		s.rebind(upsertClipQuery(s.driver)),
 		clip.URL,
 		clip.Title,
 		clip.Author,
-		clip.PublishedAt,
-		clip.ModifiedAt,
+		nullableTime(clip.PublishedAt),
+		nullableTime(clip.ModifiedAt),
 		clip.Excerpt,
 		clip.HTMLContent,
 		clip.PlainTextContent,
+		clip.WordCount,
+		clip.ReadingTimeMinutes,
+		clip.Language,
+		clip.Image,
+		clip.SiteName,
+		clip.Favicon,
+		clip.MarkdownContent,
+		clip.Read,
+		clip.Starred,
+		cmp.Or(clip.ClippedAt, time.Now()).UTC(),
 	)
-	return err
+	if err != nil {
+		return err
+	}
+
+	if err := s.storeTags(ctx, tx, clip.URL, clip.Tags); err != nil {
+		return fmt.Errorf("failed to store tags: %w", err)
+	}
+
+	var id sql.NullInt64
+	var createdAt nullTime
+	if err := tx.QueryRowContext(ctx, s.rebind("SELECT id, created_at FROM clip WHERE url = $1"), clip.URL).Scan(&id, &createdAt); err != nil {
+		return fmt.Errorf("failed to get stored clip: %w", err)
+	}
+
+	if err := s.commit(ctx, tx, event); err != nil {
+		return err
+	}
+
+	clip.ID = id.Int64
+	clip.ClippedAt = createdAt.Time
+	return nil
 }
 
-func (s *SqlStore) parseTime(ns sql.NullString) time.Time {
-	var t time.Time
-	if ns.Valid {
-		switch s.driver {
-		case sqlite3DriverName:
-			t, _ = time.Parse("2006-01-02 15:04:05.999999999-07:00", ns.String)
-		case postgresDriverName:
-			t, _ = time.Parse(time.RFC3339, ns.String)
+// storeTags replaces the tags of the clip with the given URL.
+func (s *SqlStore) storeTags(ctx context.Context, tx *sql.Tx, url string, tags []string) error {
+	if _, err := tx.ExecContext(ctx, s.rebind("DELETE FROM clip_tag WHERE clip_url = $1"), url); err != nil {
+		return err
+	}
+	insert := "INSERT INTO clip_tag (clip_url, tag) VALUES ($1, $2) ON CONFLICT DO NOTHING"
+	if s.driver == mysqlDriverName {
+		insert = "INSERT IGNORE INTO clip_tag (clip_url, tag) VALUES ($1, $2)"
+	}
+	for _, tag := range tags {
+		_, err := tx.ExecContext(ctx, s.rebind(insert), url, tag)
+		if err != nil {
+			return err
 		}
 	}
-	return t
+	return nil
+}
+
+// loadTags sets the tags on the given clips.
+func (s *SqlStore) loadTags(ctx context.Context, clips []*clip.Clip) error {
+	if len(clips) == 0 {
+		return nil
+	}
+
+	byURL := make(map[string]*clip.Clip, len(clips))
+	placeholders := make([]string, 0, len(clips))
+	args := make([]any, 0, len(clips))
+	for _, c := range clips {
+		byURL[c.URL] = c
+		args = append(args, c.URL)
+		placeholders = append(placeholders, fmt.Sprintf("$%d", len(args)))
+	}
+
+	rows, err := s.db.QueryContext(ctx,
+		s.rebind("SELECT clip_url, tag FROM clip_tag WHERE clip_url IN ("+strings.Join(placeholders, ", ")+") ORDER BY tag"),
+		args...,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var url, tag string
+		if err := rows.Scan(&url, &tag); err != nil {
+			return err
+		}
+		byURL[url].Tags = append(byURL[url].Tags, tag)
+	}
+	return rows.Err()
+}
+
+// clipColumns are the columns scanned by scanClip.
+// The plain text and markdown content is only loaded for single clips.
+const clipColumns = `
+			id, url, title, author,
+			published_at, modified_at,
+			excerpt, html_content,
+			word_count, reading_time_minutes, language,
+			image, site_name, favicon,
+			is_read, is_starred, created_at`
+
+// scanClip scans a row with the clipColumns and the given additional destinations.
+func (s *SqlStore) scanClip(row interface{ Scan(dest ...any) error }, dest ...any) (*clip.Clip, error) {
+	c := &clip.Clip{}
+
+	// Use temporary variables for nullable fields
+	var id sql.NullInt64
+	var title, author, excerpt, htmlContent sql.NullString
+	var publishedAt, modifiedAt, createdAt nullTime
+	var wordCount, readingTimeMinutes sql.NullInt64
+	var language sql.NullString
+	var image, siteName, favicon sql.NullString
+
+	err := row.Scan(append([]any{
+		&id,
+		&c.URL,
+		&title,
+		&author,
+		&publishedAt,
+		&modifiedAt,
+		&excerpt,
+		&htmlContent,
+		&wordCount,
+		&readingTimeMinutes,
+		&language,
+		&image,
+		&siteName,
+		&favicon,
+		&c.Read,
+		&c.Starred,
+		&createdAt,
+	}, dest...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	c.ID = id.Int64
+	c.Title = title.String
+	c.Author = author.String
+	c.Excerpt = excerpt.String
+	c.HTMLContent = htmlContent.String
+	c.WordCount = int(wordCount.Int64)
+	c.ReadingTimeMinutes = int(readingTimeMinutes.Int64)
+	c.Language = language.String
+	c.Image = image.String
+	c.SiteName = siteName.String
+	c.Favicon = favicon.String
+
+	c.PublishedAt = publishedAt.Time
+	c.ModifiedAt = modifiedAt.Time
+	c.ClippedAt = createdAt.Time
+
+	return c, nil
+}
+
+func (s *SqlStore) rebind(query string) string {
+	return rebind(s.driver, query)
 }
@@ -0,0 +1,90 @@
+package store_test
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/timofurrer/influss/internal/store"
+	"github.com/timofurrer/influss/internal/store/storetest"
+)
+
+func TestMemoryStore(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.Store {
+		return store.NewMemoryStore()
+	})
+}
+
+func TestFSStore(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.Store {
+		s, err := store.NewFSStore(t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	})
+}
+
+func TestSqlStoreSqlite3(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.Store {
+		return newSqlStore(t, "sqlite3://"+filepath.Join(t.TempDir(), "influss.sqlite"))
+	})
+}
+
+func TestSqlStoreSqlite(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.Store {
+		return newSqlStore(t, "sqlite://"+filepath.Join(t.TempDir(), "influss.sqlite"))
+	})
+}
+
+// TestSqlStorePostgres runs against the database of the postgres:// URL in INFLUSS_TEST_POSTGRES,
+// which is emptied before every test.
+func TestSqlStorePostgres(t *testing.T) {
+	runSharedSqlStore(t, "INFLUSS_TEST_POSTGRES")
+}
+
+// TestSqlStoreMySQL runs against the database of the mysql:// or mariadb:// URL in INFLUSS_TEST_MYSQL,
+// which is emptied before every test.
+func TestSqlStoreMySQL(t *testing.T) {
+	runSharedSqlStore(t, "INFLUSS_TEST_MYSQL")
+}
+
+func runSharedSqlStore(t *testing.T, env string) {
+	connectionString := os.Getenv(env)
+	if connectionString == "" {
+		t.Skipf("%s is not set", env)
+	}
+	storetest.Run(t, func(t *testing.T) store.Store {
+		s := newSqlStore(t, connectionString)
+		clips, _, err := s.List(context.Background(), store.Query{})
+		if err != nil {
+			t.Fatalf("failed to list clips: %v", err)
+		}
+		for _, c := range clips {
+			if err := s.Delete(context.Background(), c.URL); err != nil {
+				t.Fatalf("failed to delete clip: %v", err)
+			}
+		}
+		return s
+	})
+}
+
+func newSqlStore(t *testing.T, connectionString string) *store.SqlStore {
+	s, err := store.NewSqlStore(slog.New(slog.NewTextHandler(testWriter{t}, nil)), store.SqlConfig{ConnectionString: connectionString})
+	if err != nil {
+		t.Fatalf("failed to create SQL store: %v", err)
+	}
+	return s
+}
+
+// testWriter writes the logs of the store to the test log.
+type testWriter struct {
+	t *testing.T
+}
+
+func (w testWriter) Write(p []byte) (int, error) {
+	w.t.Log(string(p))
+	return len(p), nil
+}
@@ -0,0 +1,443 @@
+// Package storetest is a behavioral test suite for store.Store implementations,
+// which checks that all backends store, order, filter and paginate clips the same way.
+//
+// A backend runs the suite from a test with a factory creating an empty store:
+//
+//	func TestFSStore(t *testing.T) {
+//		storetest.Run(t, func(t *testing.T) store.Store {
+//			s, err := store.NewFSStore(t.TempDir())
+//			if err != nil {
+//				t.Fatal(err)
+//			}
+//			return s
+//		})
+//	}
+package storetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/timofurrer/influss/internal/clip"
+	"github.com/timofurrer/influss/internal/store"
+)
+
+// Factory creates an empty store for a test.
+type Factory func(t *testing.T) store.Store
+
+// timePrecision is the precision of the stored times, Postgres stores microseconds.
+const timePrecision = time.Microsecond
+
+// eventTimeout is how long to wait for an event, Postgres delivers them asynchronously.
+const eventTimeout = 5 * time.Second
+
+// Run runs the suite with a new store from the factory for every test.
+func Run(t *testing.T, newStore Factory) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, s store.Store)
+	}{
+		{"StoreAndGet", testStoreAndGet},
+		{"NotFound", testNotFound},
+		{"Upsert", testUpsert},
+		{"ClippedAtDefaultsToNow", testClippedAtDefaultsToNow},
+		{"TimesRoundTrip", testTimesRoundTrip},
+		{"Order", testOrder},
+		{"Pagination", testPagination},
+		{"Filters", testFilters},
+		{"Delete", testDelete},
+		{"Subscribe", testSubscribe},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, newStore(t))
+		})
+	}
+}
+
+var base = time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+
+// newClip returns a clip with all fields set, clipped at the given offset from the base time.
+func newClip(url string, offset time.Duration) *clip.Clip {
+	return &clip.Clip{
+		URL:                url,
+		Title:              "Title of " + url,
+		Author:             "Jane Doe",
+		PublishedAt:        base.Add(-24 * time.Hour),
+		ModifiedAt:         base.Add(-12 * time.Hour),
+		Excerpt:            "An excerpt",
+		HTMLContent:        "<p>Some content</p>",
+		PlainTextContent:   "Some content",
+		MarkdownContent:    "Some content\n",
+		WordCount:          2,
+		ReadingTimeMinutes: 1,
+		Language:           "en",
+		Image:              "https://example.com/image.png",
+		SiteName:           "Example",
+		Favicon:            "https://example.com/favicon.ico",
+		ClippedAt:          base.Add(offset),
+		Tags:               []string{"b", "a"},
+	}
+}
+
+func mustStore(t *testing.T, s store.Store, clips ...*clip.Clip) {
+	t.Helper()
+	for _, c := range clips {
+		if err := s.Store(context.Background(), c); err != nil {
+			t.Fatalf("failed to store %s: %v", c.URL, err)
+		}
+	}
+}
+
+func testStoreAndGet(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	want := newClip("https://example.com/a", 0)
+	mustStore(t, s, want)
+	if want.ID == 0 {
+		t.Fatal("Store didn't assign an ID")
+	}
+	if !want.ClippedAt.Equal(base) {
+		t.Errorf("Store changed the given ClippedAt to %v", want.ClippedAt)
+	}
+
+	got, err := s.Get(ctx, want.URL)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	assertClip(t, got, want, true)
+
+	got, err = s.GetByID(ctx, want.ID)
+	if err != nil {
+		t.Fatalf("GetByID failed: %v", err)
+	}
+	assertClip(t, got, want, true)
+
+	clips, total, err := s.List(ctx, store.Query{})
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if total != 1 || len(clips) != 1 {
+		t.Fatalf("List returned %d clips of %d, want 1 of 1", len(clips), total)
+	}
+	assertClip(t, clips[0], want, false)
+
+	loaded := s.Load(ctx, 10)
+	if len(loaded) != 1 {
+		t.Fatalf("Load returned %d clips, want 1", len(loaded))
+	}
+	assertClip(t, loaded[0], want, false)
+}
+
+func testNotFound(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	if _, err := s.Get(ctx, "https://example.com/missing"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Get of a missing clip returned %v, want ErrNotFound", err)
+	}
+	if _, err := s.GetByID(ctx, 42); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("GetByID of a missing clip returned %v, want ErrNotFound", err)
+	}
+	if err := s.Delete(ctx, "https://example.com/missing"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Delete of a missing clip returned %v, want ErrNotFound", err)
+	}
+}
+
+func testUpsert(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	first := newClip("https://example.com/a", 0)
+	mustStore(t, s, first)
+
+	second := newClip("https://example.com/a", time.Hour)
+	second.Title = "Updated title"
+	second.Tags = []string{"c"}
+	second.Read = true
+	second.Starred = true
+	mustStore(t, s, second)
+
+	if second.ID != first.ID {
+		t.Errorf("updating a clip changed its ID from %d to %d", first.ID, second.ID)
+	}
+	if !second.ClippedAt.Equal(first.ClippedAt) {
+		t.Errorf("updating a clip changed its ClippedAt from %v to %v", first.ClippedAt, second.ClippedAt)
+	}
+
+	got, err := s.Get(ctx, first.URL)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	want := *second
+	want.ClippedAt = first.ClippedAt
+	assertClip(t, got, &want, true)
+
+	if _, total, _ := s.List(ctx, store.Query{}); total != 1 {
+		t.Errorf("List returned a total of %d clips after an update, want 1", total)
+	}
+}
+
+func testClippedAtDefaultsToNow(t *testing.T, s store.Store) {
+	c := newClip("https://example.com/a", 0)
+	c.ClippedAt = time.Time{}
+	before := time.Now().Add(-time.Second)
+	mustStore(t, s, c)
+	after := time.Now().Add(time.Second)
+
+	if c.ClippedAt.Before(before) || c.ClippedAt.After(after) {
+		t.Errorf("Store set ClippedAt to %v, want the current time", c.ClippedAt)
+	}
+	got, err := s.Get(context.Background(), c.URL)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	assertTime(t, "ClippedAt", got.ClippedAt, c.ClippedAt)
+}
+
+func testTimesRoundTrip(t *testing.T, s store.Store) {
+	zone := time.FixedZone("UTC+5:30", 5*3600+1800)
+	c := newClip("https://example.com/a", 0)
+	c.PublishedAt = time.Date(2023, 7, 1, 8, 30, 15, 123456000, zone)
+	c.ModifiedAt = time.Time{}
+	c.ClippedAt = time.Date(2024, 1, 2, 3, 4, 5, 678901000, time.UTC)
+	mustStore(t, s, c)
+
+	got, err := s.Get(context.Background(), c.URL)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	assertTime(t, "PublishedAt", got.PublishedAt, c.PublishedAt)
+	assertTime(t, "ModifiedAt", got.ModifiedAt, c.ModifiedAt)
+	assertTime(t, "ClippedAt", got.ClippedAt, c.ClippedAt)
+}
+
+func testOrder(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	// stored out of order, b and c are clipped at the same time and ordered by ID
+	mustStore(t, s,
+		newClip("https://example.com/b", 2*time.Hour),
+		newClip("https://example.com/a", time.Hour),
+		newClip("https://example.com/d", 3*time.Hour),
+		newClip("https://example.com/c", 2*time.Hour),
+	)
+
+	clips, _, err := s.List(ctx, store.Query{})
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	assertURLs(t, "List", clips, "d", "c", "b", "a")
+
+	clips, _, err = s.List(ctx, store.Query{Ascending: true})
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	assertURLs(t, "List ascending", clips, "a", "b", "c", "d")
+
+	assertURLs(t, "Load", s.Load(ctx, 1), "d")
+}
+
+func testPagination(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	for i := range 5 {
+		mustStore(t, s, newClip(fmt.Sprintf("https://example.com/%d", i), time.Duration(i)*time.Hour))
+	}
+
+	clips, total, err := s.List(ctx, store.Query{Offset: 1, Limit: 2})
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if total != 5 {
+		t.Errorf("List returned a total of %d clips, want 5", total)
+	}
+	assertURLs(t, "List with offset and limit", clips, "3", "2")
+
+	clips, _, err = s.List(ctx, store.Query{Offset: 4, Limit: 10})
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	assertURLs(t, "List past the end", clips, "0")
+
+	clips, _, err = s.List(ctx, store.Query{Offset: 10})
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	assertURLs(t, "List beyond the end", clips)
+}
+
+func testFilters(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	a := newClip("https://example.com/a", time.Hour)
+	a.Tags = []string{"go", "db"}
+	b := newClip("https://blog.example.com/b", 2*time.Hour)
+	b.Tags = []string{"go"}
+	b.Read = true
+	b.Title = "All About SQLite"
+	b.ReadingTimeMinutes = 10
+	c := newClip("https://other.org/c", 3*time.Hour)
+	c.Tags = nil
+	c.SiteName = "Other 100%"
+	c.Starred = true
+	c.ReadingTimeMinutes = 0
+	mustStore(t, s, a, b, c)
+
+	read, unread := true, false
+	tests := []struct {
+		name string
+		q    store.Query
+		want []string
+	}{
+		{"tag", store.Query{Tags: []string{"go"}}, []string{"b", "a"}},
+		{"all tags", store.Query{Tags: []string{"go", "db"}}, []string{"a"}},
+		{"read", store.Query{Read: &read}, []string{"b"}},
+		{"unread", store.Query{Read: &unread}, []string{"c", "a"}},
+		{"starred", store.Query{Starred: &read}, []string{"c"}},
+		{"unread and unstarred", store.Query{Read: &unread, Starred: &unread}, []string{"a"}},
+		{"since", store.Query{Since: base.Add(time.Hour)}, []string{"c", "b"}},
+		{"until", store.Query{Until: base.Add(3 * time.Hour)}, []string{"b", "a"}},
+		{"domain with subdomains", store.Query{Domain: "Example.com"}, []string{"b", "a"}},
+		{"subdomain", store.Query{Domain: "blog.example.com"}, []string{"b"}},
+		{"search ignoring case", store.Query{Search: "sqlite"}, []string{"b"}},
+		{"search with wildcard", store.Query{Search: "100%"}, []string{"c"}},
+		{"search without match", store.Query{Search: "nothing"}, nil},
+		{"reading time", store.Query{MaxReadingMinutes: 5}, []string{"c", "a"}},
+		{"combined", store.Query{Tags: []string{"go"}, Read: &unread, Domain: "example.com"}, []string{"a"}},
+	}
+	for _, tt := range tests {
+		clips, total, err := s.List(ctx, tt.q)
+		if err != nil {
+			t.Errorf("List by %s failed: %v", tt.name, err)
+			continue
+		}
+		if total != len(tt.want) {
+			t.Errorf("List by %s returned a total of %d clips, want %d", tt.name, total, len(tt.want))
+		}
+		assertURLs(t, "List by "+tt.name, clips, tt.want...)
+	}
+}
+
+func testDelete(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	a := newClip("https://example.com/a", time.Hour)
+	b := newClip("https://example.com/b", 2*time.Hour)
+	mustStore(t, s, a, b)
+
+	if err := s.Delete(ctx, a.URL); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := s.Get(ctx, a.URL); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Get of a deleted clip returned %v, want ErrNotFound", err)
+	}
+	clips, _, err := s.List(ctx, store.Query{})
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	assertURLs(t, "List after delete", clips, "b")
+
+	// the URL can be clipped again
+	mustStore(t, s, newClip(a.URL, 3*time.Hour))
+	if _, err := s.Get(ctx, a.URL); err != nil {
+		t.Errorf("Get of a clip stored again after deleting it failed: %v", err)
+	}
+}
+
+func testSubscribe(t *testing.T, s store.Store) {
+	ctx, cancel := context.WithCancel(context.Background())
+	events := s.Subscribe(ctx)
+
+	c := newClip("https://example.com/a", 0)
+	mustStore(t, s, c)
+	mustStore(t, s, c)
+	if err := s.Delete(ctx, c.URL); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+
+	for _, want := range []store.ChangeType{store.ChangeCreated, store.ChangeUpdated, store.ChangeDeleted} {
+		select {
+		case e := <-events:
+			if e.Type != want || e.URL != c.URL {
+				t.Errorf("got event %s of %s, want %s of %s", e.Type, e.URL, want, c.URL)
+			}
+		case <-time.After(eventTimeout):
+			t.Fatalf("didn't get the %s event", want)
+		}
+	}
+
+	cancel()
+	timeout := time.After(eventTimeout)
+	for {
+		select {
+		case _, ok := <-events:
+			if !ok {
+				return
+			}
+		case <-timeout:
+			t.Fatal("the events weren't closed after canceling the subscription")
+		}
+	}
+}
+
+type field struct {
+	name      string
+	got, want any
+}
+
+// assertClip compares the clips, the content is only compared if withContent is set
+// because listed clips come without it. Tags are compared regardless of their order.
+func assertClip(t *testing.T, got, want *clip.Clip, withContent bool) {
+	t.Helper()
+	if got.ID != want.ID {
+		t.Errorf("ID = %d, want %d", got.ID, want.ID)
+	}
+	fields := []field{
+		{"URL", got.URL, want.URL},
+		{"Title", got.Title, want.Title},
+		{"Author", got.Author, want.Author},
+		{"Excerpt", got.Excerpt, want.Excerpt},
+		{"HTMLContent", got.HTMLContent, want.HTMLContent},
+		{"WordCount", got.WordCount, want.WordCount},
+		{"ReadingTimeMinutes", got.ReadingTimeMinutes, want.ReadingTimeMinutes},
+		{"Language", got.Language, want.Language},
+		{"Image", got.Image, want.Image},
+		{"SiteName", got.SiteName, want.SiteName},
+		{"Favicon", got.Favicon, want.Favicon},
+		{"Read", got.Read, want.Read},
+		{"Starred", got.Starred, want.Starred},
+	}
+	if withContent {
+		fields = append(fields,
+			field{"PlainTextContent", got.PlainTextContent, want.PlainTextContent},
+			field{"MarkdownContent", got.MarkdownContent, want.MarkdownContent},
+		)
+	}
+	for _, f := range fields {
+		if f.got != f.want {
+			t.Errorf("%s = %v, want %v", f.name, f.got, f.want)
+		}
+	}
+	if gotTags, wantTags := slices.Sorted(slices.Values(got.Tags)), slices.Sorted(slices.Values(want.Tags)); !slices.Equal(gotTags, wantTags) {
+		t.Errorf("Tags = %v, want %v", got.Tags, want.Tags)
+	}
+	assertTime(t, "PublishedAt", got.PublishedAt, want.PublishedAt)
+	assertTime(t, "ModifiedAt", got.ModifiedAt, want.ModifiedAt)
+	assertTime(t, "ClippedAt", got.ClippedAt, want.ClippedAt)
+}
+
+func assertTime(t *testing.T, name string, got, want time.Time) {
+	t.Helper()
+	if !got.Truncate(timePrecision).Equal(want.Truncate(timePrecision)) {
+		t.Errorf("%s = %v, want %v", name, got, want)
+	}
+}
+
+// assertURLs checks the order of the clips by the last path segment of their URLs.
+func assertURLs(t *testing.T, name string, clips []*clip.Clip, want ...string) {
+	t.Helper()
+	var got []string
+	for _, c := range clips {
+		got = append(got, c.URL[strings.LastIndex(c.URL, "/")+1:])
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("%s returned the clips %v, want %v", name, got, want)
+	}
+}
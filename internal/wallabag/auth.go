@@ -0,0 +1,136 @@
+package wallabag
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	accessTokenLifetime  = time.Hour
+	refreshTokenLifetime = 14 * 24 * time.Hour
+
+	accessTokenType  = "access"
+	refreshTokenType = "refresh"
+)
+
+type tokenResponse struct {
+	AccessToken  string  `json:"access_token"`
+	ExpiresIn    int     `json:"expires_in"`
+	RefreshToken string  `json:"refresh_token"`
+	Scope        *string `json:"scope"`
+	TokenType    string  `json:"token_type"`
+}
+
+type errorResponse struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// tokenFunc implements the OAuth token endpoint with the password and refresh_token grants.
+func (s *Server) tokenFunc(w http.ResponseWriter, r *http.Request) {
+	params, err := requestParams(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid_request", ErrorDescription: err.Error()})
+		return
+	}
+
+	if !equal(params.Get("client_id"), s.cfg.ClientID) || !equal(params.Get("client_secret"), s.cfg.ClientSecret) {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid_client", ErrorDescription: "The client credentials are invalid"})
+		return
+	}
+
+	switch params.Get("grant_type") {
+	case "password":
+		if !equal(params.Get("username"), s.cfg.Username) || !equal(params.Get("password"), s.cfg.Password) {
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid_grant", ErrorDescription: "Invalid username and password combination"})
+			return
+		}
+	case "refresh_token":
+		if !s.validToken(params.Get("refresh_token"), refreshTokenType) {
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid_grant", ErrorDescription: "Invalid refresh token"})
+			return
+		}
+	default:
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "unsupported_grant_type", ErrorDescription: "Invalid grant_type parameter or parameter missing"})
+		return
+	}
+
+	now := time.Now()
+	writeJSON(w, http.StatusOK, tokenResponse{
+		AccessToken:  s.newToken(accessTokenType, now.Add(accessTokenLifetime)),
+		ExpiresIn:    int(accessTokenLifetime.Seconds()),
+		RefreshToken: s.newToken(refreshTokenType, now.Add(refreshTokenLifetime)),
+		TokenType:    "bearer",
+	})
+}
+
+// authenticated only calls next for requests with a valid access token.
+func (s *Server) authenticated(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !found {
+			token = r.URL.Query().Get("access_token")
+		}
+
+		if !s.validToken(token, accessTokenType) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="Service", error="invalid_grant", error_description="The access token provided is invalid."`)
+			writeJSON(w, http.StatusUnauthorized, errorResponse{Error: "invalid_grant", ErrorDescription: "The access token provided is invalid."})
+			return
+		}
+		next(w, r)
+	}
+}
+
+// newToken creates a stateless token of the given type, which is valid until the given expiry.
+// Tokens are signed with a key derived from the configured credentials,
+// so changing the credentials invalidates all tokens.
+func (s *Server) newToken(tokenType string, expiry time.Time) string {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%s|%d", tokenType, expiry.Unix())))
+	return payload + "." + s.sign(payload)
+}
+
+func (s *Server) validToken(token, tokenType string) bool {
+	payload, signature, found := strings.Cut(token, ".")
+	if !found || !hmac.Equal([]byte(signature), []byte(s.sign(payload))) {
+		return false
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return false
+	}
+	t, expiry, found := strings.Cut(string(data), "|")
+	if !found || t != tokenType {
+		return false
+	}
+	unix, err := strconv.ParseInt(expiry, 10, 64)
+	if err != nil {
+		return false
+	}
+	return time.Now().Before(time.Unix(unix, 0))
+}
+
+func (s *Server) sign(payload string) string {
+	key := sha256.Sum256([]byte(s.cfg.ClientSecret + "\x00" + s.cfg.Password))
+	mac := hmac.New(sha256.New, key[:])
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func equal(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
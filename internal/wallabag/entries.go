@@ -0,0 +1,485 @@
+package wallabag
+
+import (
+	"cmp"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/timofurrer/influss/internal/clip"
+	"github.com/timofurrer/influss/internal/store"
+)
+
+const (
+	defaultPerPage = 30
+	maxPerPage     = 500
+)
+
+type entry struct {
+	ID             int64    `json:"id"`
+	URL            string   `json:"url"`
+	GivenURL       string   `json:"given_url"`
+	HashedURL      string   `json:"hashed_url"`
+	Title          string   `json:"title"`
+	Content        string   `json:"content,omitempty"`
+	IsArchived     int      `json:"is_archived"`
+	IsStarred      int      `json:"is_starred"`
+	IsPublic       bool     `json:"is_public"`
+	Tags           []tag    `json:"tags"`
+	CreatedAt      string   `json:"created_at"`
+	UpdatedAt      string   `json:"updated_at"`
+	PublishedAt    *string  `json:"published_at"`
+	PublishedBy    []string `json:"published_by"`
+	ArchivedAt     *string  `json:"archived_at"`
+	StarredAt      *string  `json:"starred_at"`
+	ReadingTime    int      `json:"reading_time"`
+	DomainName     string   `json:"domain_name"`
+	PreviewPicture *string  `json:"preview_picture"`
+	Mimetype       string   `json:"mimetype"`
+	Language       *string  `json:"language"`
+	HTTPStatus     string   `json:"http_status"`
+	UserID         int      `json:"user_id"`
+	UserName       string   `json:"user_name"`
+	UserEmail      string   `json:"user_email"`
+	Annotations    []any    `json:"annotations"`
+	Links          links    `json:"_links"`
+}
+
+type tag struct {
+	ID    uint32 `json:"id"`
+	Label string `json:"label"`
+	Slug  string `json:"slug"`
+}
+
+type link struct {
+	Href string `json:"href"`
+}
+
+type links struct {
+	Self  *link `json:"self,omitempty"`
+	First *link `json:"first,omitempty"`
+	Last  *link `json:"last,omitempty"`
+	Next  *link `json:"next,omitempty"`
+	Prev  *link `json:"previous,omitempty"`
+}
+
+type entriesResponse struct {
+	Page     int   `json:"page"`
+	Limit    int   `json:"limit"`
+	Pages    int   `json:"pages"`
+	Total    int   `json:"total"`
+	Links    links `json:"_links"`
+	Embedded struct {
+		Items []entry `json:"items"`
+	} `json:"_embedded"`
+}
+
+func (s *Server) listEntriesFunc(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+
+	q := store.Query{Tags: splitTags(params.Get("tags"))}
+	read, err := flagParam(params, "archive")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	q.Read = read
+	q.Starred, err = flagParam(params, "starred")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if v := params.Get("since"); v != "" {
+		since, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid since parameter %q", v))
+			return
+		}
+		q.Since = time.Unix(since, 0)
+	}
+	q.Ascending = params.Get("order") == "asc"
+
+	page, err := intParam(params, "page", 1)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	perPage, err := intParam(params, "perPage", defaultPerPage)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	perPage = min(perPage, maxPerPage)
+	q.Offset = (page - 1) * perPage
+	q.Limit = perPage
+
+	clips, total, err := s.store.List(r.Context(), q)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to list entries: %s", err))
+		return
+	}
+
+	withContent := params.Get("detail") != "metadata"
+	resp := entriesResponse{
+		Page:  page,
+		Limit: perPage,
+		Pages: max(1, (total+perPage-1)/perPage),
+		Total: total,
+	}
+	resp.Embedded.Items = make([]entry, 0, len(clips))
+	for _, c := range clips {
+		resp.Embedded.Items = append(resp.Embedded.Items, s.newEntry(c, withContent))
+	}
+	resp.Links = pageLinks(r.URL, page, resp.Pages)
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) getEntryFunc(w http.ResponseWriter, r *http.Request) {
+	c, ok := s.lookupEntry(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, s.newEntry(c, true))
+}
+
+func (s *Server) entryExistsFunc(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	u := params.Get("url")
+	if u == "" {
+		writeError(w, http.StatusBadRequest, "url parameter is required")
+		return
+	}
+
+	c, err := s.store.Get(r.Context(), u)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to get entry: %s", err))
+		return
+	}
+
+	if params.Get("return_id") == "1" {
+		var id *int64
+		if c != nil {
+			id = &c.ID
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"exists": id})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"exists": c != nil})
+}
+
+// createEntryFunc clips the given URL and stores it as a new entry.
+// Existing entries are updated without clipping the URL again, unless content is given.
+func (s *Server) createEntryFunc(w http.ResponseWriter, r *http.Request) {
+	params, err := requestParams(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	u := params.Get("url")
+	if u == "" {
+		writeError(w, http.StatusBadRequest, "url parameter is required")
+		return
+	}
+	read, err := flagParam(params, "archive")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	starred, err := flagParam(params, "starred")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	log := s.log.With(slog.String("url", u))
+	log.Info("Received request to create wallabag entry")
+
+	existing, err := s.store.Get(r.Context(), u)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to get entry: %s", err))
+		return
+	}
+
+	c := existing
+	switch {
+	case params.Get("content") != "":
+		c, err = clip.FromContent(u, params.Get("title"), params.Get("content"))
+	case existing == nil:
+		c, err = s.clipper.ClipURL(u)
+	}
+	if err != nil {
+		log.Warn("Unable to clip entry, storing link only", slog.String("error", err.Error()))
+		now := time.Now()
+		c = &clip.Clip{URL: u, PublishedAt: now, ModifiedAt: now}
+	}
+
+	if existing != nil {
+		c.ID = existing.ID
+		c.ClippedAt = existing.ClippedAt
+		c.Tags = existing.Tags
+		c.Read = existing.Read
+		c.Starred = existing.Starred
+	}
+	c.Title = cmp.Or(params.Get("title"), c.Title, u)
+	c.Author = cmp.Or(params.Get("authors"), c.Author)
+	c.Tags = addTags(c.Tags, splitTags(params.Get("tags")))
+	if read != nil {
+		c.Read = *read
+	}
+	if starred != nil {
+		c.Starred = *starred
+	}
+
+	if err := s.store.Store(r.Context(), c); err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to store entry: %s", err))
+		return
+	}
+	writeJSON(w, http.StatusOK, s.newEntry(c, true))
+}
+
+func (s *Server) updateEntryFunc(w http.ResponseWriter, r *http.Request) {
+	c, ok := s.lookupEntry(w, r)
+	if !ok {
+		return
+	}
+
+	params, err := requestParams(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	read, err := flagParam(params, "archive")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	starred, err := flagParam(params, "starred")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if read != nil {
+		c.Read = *read
+	}
+	if starred != nil {
+		c.Starred = *starred
+	}
+	if title := params.Get("title"); title != "" {
+		c.Title = title
+	}
+	c.Tags = addTags(c.Tags, splitTags(params.Get("tags")))
+
+	if err := s.store.Store(r.Context(), c); err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to store entry: %s", err))
+		return
+	}
+	writeJSON(w, http.StatusOK, s.newEntry(c, true))
+}
+
+func (s *Server) deleteEntryFunc(w http.ResponseWriter, r *http.Request) {
+	c, ok := s.lookupEntry(w, r)
+	if !ok {
+		return
+	}
+
+	if err := s.store.Delete(r.Context(), c.URL); err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to delete entry: %s", err))
+		return
+	}
+	writeJSON(w, http.StatusOK, s.newEntry(c, true))
+}
+
+// deleteEntryTagFunc removes a tag, given by its id, from an entry.
+func (s *Server) deleteEntryTagFunc(w http.ResponseWriter, r *http.Request) {
+	c, ok := s.lookupEntry(w, r)
+	if !ok {
+		return
+	}
+
+	tagID, err := strconv.ParseUint(strings.TrimSuffix(r.PathValue("tag"), ".json"), 10, 32)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid tag id")
+		return
+	}
+	c.Tags = slices.DeleteFunc(c.Tags, func(t string) bool {
+		return tagIDFor(t) == uint32(tagID)
+	})
+
+	if err := s.store.Store(r.Context(), c); err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to store entry: %s", err))
+		return
+	}
+	writeJSON(w, http.StatusOK, s.newEntry(c, true))
+}
+
+// listTagsFunc lists all tags used by any entry.
+func (s *Server) listTagsFunc(w http.ResponseWriter, r *http.Request) {
+	clips, _, err := s.store.List(r.Context(), store.Query{})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to list entries: %s", err))
+		return
+	}
+
+	var labels []string
+	for _, c := range clips {
+		labels = addTags(labels, c.Tags)
+	}
+	slices.Sort(labels)
+	writeJSON(w, http.StatusOK, newTags(labels))
+}
+
+// lookupEntry gets the entry with the id in the request path.
+// It writes an error response and returns false if that fails.
+func (s *Server) lookupEntry(w http.ResponseWriter, r *http.Request) (*clip.Clip, bool) {
+	id, err := entryID(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return nil, false
+	}
+
+	c, err := s.store.GetByID(r.Context(), id)
+	if errors.Is(err, store.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "Entry not found")
+		return nil, false
+	} else if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to get entry: %s", err))
+		return nil, false
+	}
+	return c, true
+}
+
+func (s *Server) newEntry(c *clip.Clip, withContent bool) entry {
+	hash := sha1.Sum([]byte(c.URL))
+	e := entry{
+		ID:          c.ID,
+		URL:         c.URL,
+		GivenURL:    c.URL,
+		HashedURL:   hex.EncodeToString(hash[:]),
+		Title:       c.Title,
+		IsArchived:  boolInt(c.Read),
+		IsStarred:   boolInt(c.Starred),
+		Tags:        newTags(c.Tags),
+		CreatedAt:   formatTime(c.ClippedAt),
+		UpdatedAt:   formatTime(c.ClippedAt),
+		PublishedBy: []string{},
+		ReadingTime: c.ReadingTimeMinutes,
+		Mimetype:    "text/html",
+		HTTPStatus:  "200",
+		UserID:      1,
+		UserName:    s.cfg.Username,
+		Annotations: []any{},
+		Links:       links{Self: &link{Href: fmt.Sprintf("/api/entries/%d", c.ID)}},
+	}
+	if withContent {
+		e.Content = c.HTMLContent
+	}
+	if c.Author != "" {
+		e.PublishedBy = []string{c.Author}
+	}
+	if !c.PublishedAt.IsZero() {
+		e.PublishedAt = optional(formatTime(c.PublishedAt))
+	}
+	if c.Image != "" {
+		e.PreviewPicture = &c.Image
+	}
+	if c.Language != "" {
+		e.Language = &c.Language
+	}
+	if u, err := url.Parse(c.URL); err == nil {
+		e.DomainName = u.Hostname()
+	}
+	return e
+}
+
+func newTags(labels []string) []tag {
+	tags := make([]tag, 0, len(labels))
+	for _, l := range labels {
+		tags = append(tags, tag{ID: tagIDFor(l), Label: l, Slug: slug(l)})
+	}
+	return tags
+}
+
+// tagIDFor derives a stable id for a tag, because tags are only stored by their label.
+func tagIDFor(label string) uint32 {
+	return crc32.ChecksumIEEE([]byte(label))
+}
+
+func slug(label string) string {
+	return strings.Join(strings.Fields(strings.ToLower(label)), "-")
+}
+
+func splitTags(s string) []string {
+	var tags []string
+	for _, t := range strings.Split(s, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			tags = append(tags, t)
+		}
+	}
+	return tags
+}
+
+// addTags adds the given tags to the existing ones, skipping duplicates.
+func addTags(tags []string, add []string) []string {
+	for _, t := range add {
+		if !slices.Contains(tags, t) {
+			tags = append(tags, t)
+		}
+	}
+	return tags
+}
+
+func pageLinks(u *url.URL, page, pages int) links {
+	pageURL := func(p int) *link {
+		q := u.Query()
+		q.Set("page", strconv.Itoa(p))
+		return &link{Href: u.Path + "?" + q.Encode()}
+	}
+
+	l := links{Self: pageURL(page), First: pageURL(1), Last: pageURL(pages)}
+	if page < pages {
+		l.Next = pageURL(page + 1)
+	}
+	if page > 1 {
+		l.Prev = pageURL(page - 1)
+	}
+	return l
+}
+
+func intParam(params url.Values, key string, fallback int) (int, error) {
+	v := params.Get(key)
+	if v == "" {
+		return fallback, nil
+	}
+	i, err := strconv.Atoi(v)
+	if err != nil || i < 1 {
+		return 0, fmt.Errorf("invalid %s parameter %q", key, v)
+	}
+	return i, nil
+}
+
+// formatTime formats a time like wallabag does, e.g. 2024-01-02T15:04:05+0000.
+func formatTime(t time.Time) string {
+	return t.Format("2006-01-02T15:04:05-0700")
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func optional(s string) *string {
+	return &s
+}
@@ -0,0 +1,47 @@
+package wallabag
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/timofurrer/influss/internal/store"
+)
+
+func TestCreateEntrySanitizesContent(t *testing.T) {
+	s := store.NewMemoryStore()
+	srv := New(slog.New(slog.NewTextHandler(io.Discard, nil)), Config{ClientID: "id", ClientSecret: "secret", Username: "user", Password: "password"}, nil, s)
+	mux := http.NewServeMux()
+	srv.Register(mux)
+
+	params := url.Values{
+		"url":     {"https://example.com/article"},
+		"title":   {"Article"},
+		"content": {`<p onclick="steal()">Text</p><script>steal()</script><iframe src="https://example.com/frame"></iframe>`},
+	}
+	req := httptest.NewRequest(http.MethodPost, "/api/entries.json", strings.NewReader(params.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "Bearer "+srv.newToken(accessTokenType, time.Now().Add(time.Hour)))
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body)
+	}
+
+	c, err := s.Get(context.Background(), "https://example.com/article")
+	if err != nil {
+		t.Fatalf("failed to get entry: %v", err)
+	}
+	if c.HTMLContent != "<p>Text</p>" {
+		t.Errorf("expected sanitized content, got %q", c.HTMLContent)
+	}
+	if strings.Contains(rec.Body.String(), "steal") || strings.Contains(rec.Body.String(), "iframe") {
+		t.Errorf("expected sanitized content in response, got %s", rec.Body)
+	}
+}
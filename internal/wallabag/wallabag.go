@@ -0,0 +1,164 @@
+// Package wallabag implements the subset of the wallabag v2 REST API
+// which is used by the wallabag mobile apps to save and manage entries.
+package wallabag
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/timofurrer/influss/internal/clip"
+	"github.com/timofurrer/influss/internal/store"
+)
+
+// apiVersion is the wallabag version reported to clients.
+const apiVersion = "2.6.9"
+
+type Config struct {
+	ClientID     string
+	ClientSecret string
+	Username     string
+	Password     string
+}
+
+type Server struct {
+	log     *slog.Logger
+	cfg     Config
+	clipper *clip.Clipper
+	store   store.Store
+}
+
+func New(log *slog.Logger, cfg Config, clipper *clip.Clipper, store store.Store) *Server {
+	return &Server{log: log, cfg: cfg, clipper: clipper, store: store}
+}
+
+// Register registers the wallabag API endpoints on the given mux.
+// The endpoints are registered with and without the `.json` suffix the apps use.
+func (s *Server) Register(mux *http.ServeMux) {
+	mux.HandleFunc("POST /oauth/v2/token", s.tokenFunc)
+
+	handle := func(method, path string, h http.HandlerFunc) {
+		mux.HandleFunc(method+" "+path, s.authenticated(h))
+		mux.HandleFunc(method+" "+path+".json", s.authenticated(h))
+	}
+	handle("GET", "/api/version", s.versionFunc)
+	handle("GET", "/api/info", s.infoFunc)
+	handle("GET", "/api/user", s.userFunc)
+	handle("GET", "/api/tags", s.listTagsFunc)
+	handle("GET", "/api/entries", s.listEntriesFunc)
+	handle("POST", "/api/entries", s.createEntryFunc)
+	handle("GET", "/api/entries/exists", s.entryExistsFunc)
+
+	// the id wildcard also matches a `.json` suffix which is stripped by entryID
+	mux.HandleFunc("GET /api/entries/{id}", s.authenticated(s.getEntryFunc))
+	mux.HandleFunc("PATCH /api/entries/{id}", s.authenticated(s.updateEntryFunc))
+	mux.HandleFunc("DELETE /api/entries/{id}", s.authenticated(s.deleteEntryFunc))
+	mux.HandleFunc("DELETE /api/entries/{id}/tags/{tag}", s.authenticated(s.deleteEntryTagFunc))
+}
+
+func (s *Server) versionFunc(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, apiVersion)
+}
+
+func (s *Server) infoFunc(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"appname":              "wallabag",
+		"version":              apiVersion,
+		"allowed_registration": false,
+	})
+}
+
+func (s *Server) userFunc(w http.ResponseWriter, _ *http.Request) {
+	created := formatTime(s.store.CreatedAt())
+	writeJSON(w, http.StatusOK, map[string]any{
+		"id":         1,
+		"username":   s.cfg.Username,
+		"email":      "",
+		"name":       s.cfg.Username,
+		"created_at": created,
+		"updated_at": created,
+	})
+}
+
+// requestParams returns the parameters of a request from the query and the body,
+// which is either form encoded or a JSON object.
+func requestParams(r *http.Request) (url.Values, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "application/json" {
+		if err := r.ParseForm(); err != nil {
+			return nil, fmt.Errorf("failed to parse request: %w", err)
+		}
+		return r.Form, nil
+	}
+
+	params := r.URL.Query()
+	var body map[string]any
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("failed to parse request body: %w", err)
+	}
+	for k, v := range body {
+		switch v := v.(type) {
+		case nil:
+		case string:
+			params.Set(k, v)
+		case bool:
+			params.Set(k, boolParam(v))
+		case float64:
+			params.Set(k, strconv.FormatFloat(v, 'f', -1, 64))
+		case []any:
+			values := make([]string, 0, len(v))
+			for _, e := range v {
+				values = append(values, fmt.Sprint(e))
+			}
+			params.Set(k, strings.Join(values, ","))
+		default:
+			params.Set(k, fmt.Sprint(v))
+		}
+	}
+	return params, nil
+}
+
+// flagParam parses a boolean parameter given as 0/1 or true/false.
+// It returns nil if the parameter is missing.
+func flagParam(params url.Values, key string) (*bool, error) {
+	v := params.Get(key)
+	if v == "" {
+		return nil, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s parameter %q", key, v)
+	}
+	return &b, nil
+}
+
+func boolParam(b bool) string {
+	if b {
+		return "1"
+	}
+	return "0"
+}
+
+// entryID returns the id of the entry in the path, which may have a `.json` suffix.
+func entryID(r *http.Request) (int64, error) {
+	id, err := strconv.ParseInt(strings.TrimSuffix(r.PathValue("id"), ".json"), 10, 64)
+	if err != nil {
+		return 0, errors.New("invalid entry id")
+	}
+	return id, nil
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]any{
+		"error": map[string]any{
+			"code":    status,
+			"message": msg,
+		},
+	})
+}
@@ -0,0 +1,229 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/timofurrer/influss/internal/api"
+	"github.com/timofurrer/influss/internal/clip"
+	"github.com/timofurrer/influss/internal/store"
+)
+
+const pageSize = 50
+
+type listPage struct {
+	Title   string
+	Clips   []*clip.Clip
+	Total   int
+	Search  string
+	Tag     string
+	State   string
+	Return  string
+	PrevURL string
+	NextURL string
+	// Manage shows the forms to add and change clips.
+	Manage bool
+}
+
+func (u *UI) listFunc(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	data := listPage{
+		Title:  "Clips",
+		Search: strings.TrimSpace(params.Get("q")),
+		Tag:    strings.TrimSpace(params.Get("tag")),
+		State:  params.Get("state"),
+		Return: r.URL.RequestURI(),
+		Manage: !u.readOnly,
+	}
+
+	q := store.Query{Search: data.Search}
+	if data.Tag != "" {
+		q.Tags = []string{data.Tag}
+	}
+	switch data.State {
+	case "unread":
+		q.Read = new(bool)
+		q.Starred = new(bool)
+	case "read":
+		read := true
+		q.Read = &read
+	case "starred":
+		starred := true
+		q.Starred = &starred
+	default:
+		data.State = "all"
+	}
+
+	page := 1
+	if v := params.Get("page"); v != "" {
+		var err error
+		page, err = strconv.Atoi(v)
+		if err != nil || page < 1 {
+			renderError(w, http.StatusBadRequest, fmt.Errorf("invalid page %q", v))
+			return
+		}
+	}
+	q.Offset = (page - 1) * pageSize
+	q.Limit = pageSize
+
+	clips, total, err := u.store.List(r.Context(), q)
+	if err != nil {
+		renderError(w, http.StatusInternalServerError, fmt.Errorf("failed to list clips: %w", err))
+		return
+	}
+	data.Clips = clips
+	data.Total = total
+
+	pageURL := func(p int) string {
+		params.Set("page", strconv.Itoa(p))
+		return "/ui/?" + params.Encode()
+	}
+	if page > 1 {
+		data.PrevURL = pageURL(page - 1)
+	}
+	if page*pageSize < total {
+		data.NextURL = pageURL(page + 1)
+	}
+
+	render(w, http.StatusOK, "list", data)
+}
+
+type readerPage struct {
+	Title   string
+	Clip    *clip.Clip
+	Content template.HTML
+	Return  string
+	Manage  bool
+}
+
+func (u *UI) readerFunc(w http.ResponseWriter, r *http.Request) {
+	c, ok := u.lookupClip(w, r)
+	if !ok {
+		return
+	}
+	render(w, http.StatusOK, "reader", readerPage{
+		Title:   c.Title,
+		Clip:    c,
+		Content: template.HTML(contentPolicy.Sanitize(c.HTMLContent)),
+		Return:  r.URL.RequestURI(),
+		Manage:  !u.readOnly,
+	})
+}
+
+// addFunc clips the submitted URL the same way as the clips API does.
+func (u *UI) addFunc(w http.ResponseWriter, r *http.Request) {
+	pageURL := strings.TrimSpace(r.PostFormValue("url"))
+	if parsed, err := url.Parse(pageURL); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		renderError(w, http.StatusBadRequest, fmt.Errorf("invalid URL %q", pageURL))
+		return
+	}
+
+	u.log.Info("Received request to clip URL", slog.String("url", pageURL))
+
+	c, err := api.ClipAndStore(r.Context(), u.clipper, u.store, pageURL, "")
+	if err != nil {
+		renderError(w, http.StatusInternalServerError, fmt.Errorf("failed to clip URL: %w", err))
+		return
+	}
+	http.Redirect(w, r, fmt.Sprintf("/ui/clips/%d", c.ID), http.StatusSeeOther)
+}
+
+func (u *UI) deleteFunc(w http.ResponseWriter, r *http.Request) {
+	c, ok := u.lookupClip(w, r)
+	if !ok {
+		return
+	}
+
+	if err := u.store.Delete(r.Context(), c.URL); err != nil && !errors.Is(err, store.ErrNotFound) {
+		renderError(w, http.StatusInternalServerError, fmt.Errorf("failed to delete clip: %w", err))
+		return
+	}
+
+	// the reader view of a deleted clip doesn't exist anymore
+	if strings.HasPrefix(r.PostFormValue("return"), "/ui/clips/") {
+		http.Redirect(w, r, "/ui/", http.StatusSeeOther)
+		return
+	}
+	redirectBack(w, r, "/ui/")
+}
+
+func (u *UI) reclipFunc(w http.ResponseWriter, r *http.Request) {
+	c, ok := u.lookupClip(w, r)
+	if !ok {
+		return
+	}
+
+	if _, err := api.ClipAndStore(r.Context(), u.clipper, u.store, c.URL, c.Title); err != nil {
+		renderError(w, http.StatusInternalServerError, fmt.Errorf("failed to clip URL again: %w", err))
+		return
+	}
+	redirectBack(w, r, fmt.Sprintf("/ui/clips/%d", c.ID))
+}
+
+// tagsFunc replaces the tags of a clip with the submitted comma-separated tags.
+func (u *UI) tagsFunc(w http.ResponseWriter, r *http.Request) {
+	c, ok := u.lookupClip(w, r)
+	if !ok {
+		return
+	}
+
+	c.Tags = nil
+	for _, t := range strings.Split(r.PostFormValue("tags"), ",") {
+		if t = strings.TrimSpace(t); t != "" && !slices.Contains(c.Tags, t) {
+			c.Tags = append(c.Tags, t)
+		}
+	}
+
+	if err := u.store.Store(r.Context(), c); err != nil {
+		renderError(w, http.StatusInternalServerError, fmt.Errorf("failed to store clip: %w", err))
+		return
+	}
+	redirectBack(w, r, fmt.Sprintf("/ui/clips/%d", c.ID))
+}
+
+func (u *UI) readFunc(w http.ResponseWriter, r *http.Request) {
+	c, ok := u.lookupClip(w, r)
+	if !ok {
+		return
+	}
+
+	read, err := strconv.ParseBool(r.PostFormValue("read"))
+	if err != nil {
+		renderError(w, http.StatusBadRequest, fmt.Errorf("invalid read value %q", r.PostFormValue("read")))
+		return
+	}
+	c.Read = read
+
+	if err := u.store.Store(r.Context(), c); err != nil {
+		renderError(w, http.StatusInternalServerError, fmt.Errorf("failed to store clip: %w", err))
+		return
+	}
+	redirectBack(w, r, fmt.Sprintf("/ui/clips/%d", c.ID))
+}
+
+func (u *UI) starFunc(w http.ResponseWriter, r *http.Request) {
+	c, ok := u.lookupClip(w, r)
+	if !ok {
+		return
+	}
+
+	starred, err := strconv.ParseBool(r.PostFormValue("starred"))
+	if err != nil {
+		renderError(w, http.StatusBadRequest, fmt.Errorf("invalid starred value %q", r.PostFormValue("starred")))
+		return
+	}
+	c.Starred = starred
+
+	if err := u.store.Store(r.Context(), c); err != nil {
+		renderError(w, http.StatusInternalServerError, fmt.Errorf("failed to store clip: %w", err))
+		return
+	}
+	redirectBack(w, r, fmt.Sprintf("/ui/clips/%d", c.ID))
+}
@@ -0,0 +1,167 @@
+// Package web implements a server-rendered UI to browse and manage clips.
+package web
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/microcosm-cc/bluemonday"
+
+	"github.com/timofurrer/influss/internal/clip"
+	"github.com/timofurrer/influss/internal/store"
+)
+
+//go:embed templates/*.html
+var templatesFS embed.FS
+
+//go:embed static
+var staticFS embed.FS
+
+// contentSecurityPolicy disallows scripts, because the UI has none and clipped content may contain some.
+const contentSecurityPolicy = "default-src 'self'; img-src * data:; media-src *; script-src 'none'; frame-ancestors 'none'; form-action 'self'"
+
+// contentPolicy sanitizes the content of clips before it's shown.
+// Not all content went through readability, e.g. content selected by site rules, imported or received by mail.
+var contentPolicy = newContentPolicy()
+
+func newContentPolicy() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+	p.AllowDataURIImages()
+	p.AllowElements("picture")
+	p.AllowAttrs("src", "poster", "controls", "width", "height").OnElements("video")
+	p.AllowAttrs("src", "controls").OnElements("audio")
+	p.AllowAttrs("src", "type").OnElements("source")
+	return p
+}
+
+var funcs = template.FuncMap{
+	"date": func(t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return t.Format("Jan 2, 2006")
+	},
+	"tagURL": func(tag string) string {
+		return "/ui/?tag=" + url.QueryEscape(tag)
+	},
+	"join": strings.Join,
+}
+
+var pages = map[string]*template.Template{
+	"list":   parsePage("list.html"),
+	"reader": parsePage("reader.html"),
+	"error":  parsePage("error.html"),
+}
+
+func parsePage(name string) *template.Template {
+	return template.Must(template.New(name).Funcs(funcs).ParseFS(templatesFS, "templates/layout.html", "templates/"+name))
+}
+
+type UI struct {
+	log     *slog.Logger
+	clipper *clip.Clipper
+	store   store.Store
+	// readOnly disables adding and changing clips, because the UI has no login.
+	readOnly bool
+}
+
+func New(log *slog.Logger, clipper *clip.Clipper, store store.Store, readOnly bool) *UI {
+	return &UI{log: log, clipper: clipper, store: store, readOnly: readOnly}
+}
+
+// Register registers the UI pages below /ui/ on the given mux.
+// The pages to add and change clips are only registered if the UI isn't read-only.
+func (u *UI) Register(mux *http.ServeMux) {
+	static, _ := fs.Sub(staticFS, "static")
+	mux.Handle("GET /ui/static/", http.StripPrefix("/ui/static/", http.FileServerFS(static)))
+
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/ui/", http.StatusFound)
+	})
+	mux.HandleFunc("GET /ui/{$}", u.listFunc)
+	mux.HandleFunc("GET /ui/clips/{id}", u.readerFunc)
+	if u.readOnly {
+		return
+	}
+	mux.HandleFunc("POST /ui/clips", sameOrigin(u.addFunc))
+	mux.HandleFunc("POST /ui/clips/{id}/delete", sameOrigin(u.deleteFunc))
+	mux.HandleFunc("POST /ui/clips/{id}/reclip", sameOrigin(u.reclipFunc))
+	mux.HandleFunc("POST /ui/clips/{id}/tags", sameOrigin(u.tagsFunc))
+	mux.HandleFunc("POST /ui/clips/{id}/read", sameOrigin(u.readFunc))
+	mux.HandleFunc("POST /ui/clips/{id}/starred", sameOrigin(u.starFunc))
+}
+
+// sameOrigin rejects cross-site form submissions.
+// The UI has no sessions to bind CSRF tokens to, so the origin headers browsers send are checked instead.
+func sameOrigin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if site := r.Header.Get("Sec-Fetch-Site"); site != "" {
+			if site != "same-origin" && site != "none" {
+				renderError(w, http.StatusForbidden, errors.New("cross-site requests are not allowed"))
+				return
+			}
+		} else if origin := r.Header.Get("Origin"); origin != "" {
+			if u, err := url.Parse(origin); err != nil || u.Host != r.Host {
+				renderError(w, http.StatusForbidden, errors.New("cross-origin requests are not allowed"))
+				return
+			}
+		}
+		next(w, r)
+	}
+}
+
+// lookupClip gets the clip with the id in the request path.
+// It renders an error page and returns false if that fails.
+func (u *UI) lookupClip(w http.ResponseWriter, r *http.Request) (*clip.Clip, bool) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		renderError(w, http.StatusBadRequest, fmt.Errorf("invalid clip id %q", r.PathValue("id")))
+		return nil, false
+	}
+
+	c, err := u.store.GetByID(r.Context(), id)
+	if errors.Is(err, store.ErrNotFound) {
+		renderError(w, http.StatusNotFound, err)
+		return nil, false
+	} else if err != nil {
+		renderError(w, http.StatusInternalServerError, fmt.Errorf("failed to load clip: %w", err))
+		return nil, false
+	}
+	return c, true
+}
+
+// redirectBack redirects to the page given in the return form field, which defaults to the given fallback.
+// Only UI pages are allowed as target to prevent open redirects.
+func redirectBack(w http.ResponseWriter, r *http.Request, fallback string) {
+	target := r.PostFormValue("return")
+	if !strings.HasPrefix(target, "/ui/") || strings.HasPrefix(target, "//") {
+		target = fallback
+	}
+	http.Redirect(w, r, target, http.StatusSeeOther)
+}
+
+func render(w http.ResponseWriter, status int, page string, data any) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Security-Policy", contentSecurityPolicy)
+	w.WriteHeader(status)
+	pages[page].ExecuteTemplate(w, "layout", data)
+}
+
+type errorPage struct {
+	Title  string
+	Status int
+	Error  string
+}
+
+func renderError(w http.ResponseWriter, status int, err error) {
+	render(w, status, "error", errorPage{Title: http.StatusText(status), Status: status, Error: err.Error()})
+}
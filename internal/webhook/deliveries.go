@@ -0,0 +1,36 @@
+package webhook
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+)
+
+// Register registers the endpoint listing the deliveries on the given mux.
+func (d *Dispatcher) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /webhooks/deliveries", d.listDeliveriesFunc)
+}
+
+type deliveriesResponse struct {
+	Deliveries []delivery `json:"deliveries"`
+}
+
+// listDeliveriesFunc lists the pending and recent deliveries, newest first,
+// optionally filtered by the status and event query parameters.
+func (d *Dispatcher) listDeliveriesFunc(w http.ResponseWriter, r *http.Request) {
+	status := Status(r.URL.Query().Get("status"))
+	event := Event(r.URL.Query().Get("event"))
+
+	d.mu.Lock()
+	resp := deliveriesResponse{Deliveries: []delivery{}}
+	for _, dl := range slices.Backward(d.outbox.Deliveries) {
+		if (status == "" || dl.Status == status) && (event == "" || dl.Event == event) {
+			resp.Deliveries = append(resp.Deliveries, *dl)
+		}
+	}
+	d.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,429 @@
+// Package webhook delivers clip lifecycle events as signed JSON payloads to subscribed URLs.
+// Deliveries are kept in an outbox file and retried with backoff until they succeed.
+package webhook
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/timofurrer/influss/internal/clip"
+	"github.com/timofurrer/influss/internal/jsonfile"
+	"github.com/timofurrer/influss/internal/store"
+	"gopkg.in/yaml.v3"
+)
+
+// Event is a clip lifecycle event.
+type Event string
+
+const (
+	EventClipCreated Event = "clip.created"
+	EventClipUpdated Event = "clip.updated"
+	EventClipDeleted Event = "clip.deleted"
+	// EventClipFailed is sent when clipping a URL fails.
+	EventClipFailed Event = "clip.failed"
+)
+
+var Events = []Event{EventClipCreated, EventClipUpdated, EventClipDeleted, EventClipFailed}
+
+const (
+	// keptDeliveries is the number of finished deliveries kept in the outbox for debugging.
+	keptDeliveries = 100
+	minBackoff     = 30 * time.Second
+	maxBackoff     = time.Hour
+)
+
+// Subscription subscribes a URL to events.
+type Subscription struct {
+	URL string `yaml:"url"`
+	// Secret is the key of the HMAC-SHA256 signature of the payloads, they aren't signed if it's empty.
+	Secret string `yaml:"secret"`
+	// Events are the subscribed events, all events are subscribed if it's empty.
+	Events []Event `yaml:"events"`
+}
+
+// LoadSubscriptions reads the subscriptions from a YAML file with a list of subscriptions.
+func LoadSubscriptions(path string) ([]Subscription, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var subs []Subscription
+	if err := yaml.Unmarshal(data, &subs); err != nil {
+		return nil, fmt.Errorf("failed to parse webhooks file %s: %w", path, err)
+	}
+	for _, sub := range subs {
+		if sub.URL == "" {
+			return nil, fmt.Errorf("webhook in %s has no url", path)
+		}
+		for _, e := range sub.Events {
+			if !slices.Contains(Events, e) {
+				return nil, fmt.Errorf("webhook %s has invalid event %q, must be one of %v", sub.URL, e, Events)
+			}
+		}
+	}
+	return subs, nil
+}
+
+func (s Subscription) subscribes(e Event) bool {
+	return len(s.Events) == 0 || slices.Contains(s.Events, e)
+}
+
+type Config struct {
+	Subscriptions []Subscription
+	// OutboxFile persists the pending and recent deliveries.
+	OutboxFile string
+	// MaxAttempts is the number of attempts after which a delivery is given up.
+	MaxAttempts int
+	Client      *http.Client
+}
+
+// Status is the state of a delivery.
+type Status string
+
+const (
+	StatusPending   Status = "pending"
+	StatusDelivered Status = "delivered"
+	StatusFailed    Status = "failed"
+)
+
+// delivery is a payload to be delivered to a subscription.
+type delivery struct {
+	ID             string          `json:"id"`
+	Event          Event           `json:"event"`
+	URL            string          `json:"url"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         Status          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	LastStatusCode int             `json:"last_status_code,omitempty"`
+	LastError      string          `json:"last_error,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	NextAttemptAt  *time.Time      `json:"next_attempt_at,omitempty"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty"`
+}
+
+// outbox is persisted in the outbox file.
+type outbox struct {
+	Deliveries []*delivery `json:"deliveries"`
+}
+
+type Dispatcher struct {
+	log *slog.Logger
+	cfg Config
+
+	mu     sync.Mutex
+	outbox *outbox
+	// wake is signaled when a new delivery is queued.
+	wake chan struct{}
+}
+
+// New creates a dispatcher and loads the deliveries which are still pending from the outbox file.
+func New(log *slog.Logger, cfg Config) (*Dispatcher, error) {
+	cfg.MaxAttempts = cmp.Or(cfg.MaxAttempts, 8)
+	if cfg.Client == nil {
+		cfg.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	d := &Dispatcher{log: log, cfg: cfg, outbox: &outbox{}, wake: make(chan struct{}, 1)}
+
+	data, err := os.ReadFile(cfg.OutboxFile)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return nil, fmt.Errorf("failed to read webhook outbox: %w", err)
+	default:
+		if err := json.Unmarshal(data, d.outbox); err != nil {
+			return nil, fmt.Errorf("failed to parse webhook outbox: %w", err)
+		}
+	}
+	return d, nil
+}
+
+// payload is the JSON body of a delivery.
+type payload struct {
+	ID        string      `json:"id"`
+	Event     Event       `json:"event"`
+	CreatedAt time.Time   `json:"created_at"`
+	Clip      payloadClip `json:"clip"`
+	Error     string      `json:"error,omitempty"`
+}
+
+type payloadClip struct {
+	ID                 int64      `json:"id,omitempty"`
+	URL                string     `json:"url"`
+	Title              string     `json:"title,omitempty"`
+	Author             string     `json:"author,omitempty"`
+	SiteName           string     `json:"site_name,omitempty"`
+	Excerpt            string     `json:"excerpt,omitempty"`
+	ReadingTimeMinutes int        `json:"reading_time_minutes,omitempty"`
+	Tags               []string   `json:"tags,omitempty"`
+	Read               bool       `json:"read"`
+	Starred            bool       `json:"starred"`
+	PublishedAt        *time.Time `json:"published_at,omitempty"`
+	ClippedAt          *time.Time `json:"clipped_at,omitempty"`
+}
+
+// Notify queues deliveries of the event about the clip to the subscriptions of the event.
+func (d *Dispatcher) Notify(event Event, c *clip.Clip) {
+	d.notify(event, payloadClip{
+		ID:                 c.ID,
+		URL:                c.URL,
+		Title:              c.Title,
+		Author:             c.Author,
+		SiteName:           c.SiteName,
+		Excerpt:            c.Excerpt,
+		ReadingTimeMinutes: c.ReadingTimeMinutes,
+		Tags:               c.Tags,
+		Read:               c.Read,
+		Starred:            c.Starred,
+		PublishedAt:        optionalTime(c.PublishedAt),
+		ClippedAt:          optionalTime(c.ClippedAt),
+	}, "")
+}
+
+// OnChange is a store.ChangeHook which queues deliveries of the events about the changed clip.
+func (d *Dispatcher) OnChange(_ context.Context, ch store.Change) {
+	switch ch.Type {
+	case store.ChangeCreated:
+		d.Notify(EventClipCreated, ch.Clip)
+	case store.ChangeUpdated:
+		d.Notify(EventClipUpdated, ch.Clip)
+	case store.ChangeDeleted:
+		d.Notify(EventClipDeleted, ch.Clip)
+	}
+}
+
+// NotifyFailure queues deliveries of the clip.failed event.
+func (d *Dispatcher) NotifyFailure(url string, err error) {
+	d.notify(EventClipFailed, payloadClip{URL: url}, err.Error())
+}
+
+func (d *Dispatcher) notify(event Event, c payloadClip, errMsg string) {
+	now := time.Now().UTC()
+	p := payload{ID: newID(), Event: event, CreatedAt: now, Clip: c, Error: errMsg}
+	data, err := json.Marshal(p)
+	if err != nil {
+		d.log.Error("Failed to encode webhook payload", slog.String("error", err.Error()))
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	queued := false
+	for _, sub := range d.cfg.Subscriptions {
+		if !sub.subscribes(event) {
+			continue
+		}
+		d.outbox.Deliveries = append(d.outbox.Deliveries, &delivery{
+			ID:            newID(),
+			Event:         event,
+			URL:           sub.URL,
+			Payload:       data,
+			Status:        StatusPending,
+			CreatedAt:     now,
+			NextAttemptAt: &now,
+		})
+		queued = true
+	}
+	if !queued {
+		return
+	}
+	if err := d.save(); err != nil {
+		d.log.Error("Failed to save webhook outbox", slog.String("error", err.Error()))
+	}
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run delivers the pending deliveries when they are due until the context is canceled.
+func (d *Dispatcher) Run(ctx context.Context) {
+	for {
+		next := d.deliverDue(ctx)
+
+		wait := time.Minute
+		if !next.IsZero() {
+			wait = max(time.Until(next), 0)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-d.wake:
+		case <-time.After(wait):
+		}
+	}
+}
+
+// deliverDue attempts the due deliveries and returns when the next pending delivery is due.
+func (d *Dispatcher) deliverDue(ctx context.Context) time.Time {
+	d.mu.Lock()
+	var due []*delivery
+	for _, dl := range d.outbox.Deliveries {
+		if dl.Status == StatusPending && (dl.NextAttemptAt == nil || !dl.NextAttemptAt.After(time.Now())) {
+			due = append(due, dl)
+		}
+	}
+	d.mu.Unlock()
+
+	for _, dl := range due {
+		if ctx.Err() != nil {
+			break
+		}
+		d.attempt(ctx, dl)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.prune()
+	if len(due) > 0 {
+		if err := d.save(); err != nil {
+			d.log.Error("Failed to save webhook outbox", slog.String("error", err.Error()))
+		}
+	}
+
+	var next time.Time
+	for _, dl := range d.outbox.Deliveries {
+		if dl.Status == StatusPending && dl.NextAttemptAt != nil && (next.IsZero() || dl.NextAttemptAt.Before(next)) {
+			next = *dl.NextAttemptAt
+		}
+	}
+	return next
+}
+
+// attempt sends the delivery once and schedules the next attempt with exponential backoff if it fails.
+func (d *Dispatcher) attempt(ctx context.Context, dl *delivery) {
+	d.mu.Lock()
+	sub, ok := d.subscription(dl.URL)
+	d.mu.Unlock()
+	log := d.log.With(slog.String("delivery", dl.ID), slog.String("event", string(dl.Event)), slog.String("url", dl.URL))
+
+	var statusCode int
+	var err error
+	if !ok {
+		err = errors.New("subscription was removed")
+	} else {
+		statusCode, err = d.send(ctx, sub, dl)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	dl.Attempts++
+	dl.LastStatusCode = statusCode
+	if err == nil {
+		dl.Status = StatusDelivered
+		dl.LastError = ""
+		dl.DeliveredAt = optionalTime(time.Now().UTC())
+		dl.NextAttemptAt = nil
+		log.Info("Delivered webhook")
+		return
+	}
+
+	dl.LastError = err.Error()
+	if !ok || dl.Attempts >= d.cfg.MaxAttempts {
+		dl.Status = StatusFailed
+		dl.NextAttemptAt = nil
+		log.Error("Giving up on webhook delivery", slog.Int("attempts", dl.Attempts), slog.String("error", err.Error()))
+		return
+	}
+	backoff := min(minBackoff<<(dl.Attempts-1), maxBackoff)
+	dl.NextAttemptAt = optionalTime(time.Now().UTC().Add(backoff))
+	log.Warn("Failed to deliver webhook, retrying", slog.Int("attempts", dl.Attempts), slog.Duration("backoff", backoff), slog.String("error", err.Error()))
+}
+
+func (d *Dispatcher) send(ctx context.Context, sub Subscription, dl *delivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.URL, bytes.NewReader(dl.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "influss-webhook")
+	req.Header.Set("X-Influss-Event", string(dl.Event))
+	req.Header.Set("X-Influss-Delivery", dl.ID)
+	if sub.Secret != "" {
+		req.Header.Set("X-Influss-Signature", Sign(sub.Secret, dl.Payload))
+	}
+
+	resp, err := d.cfg.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<20))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// Sign returns the signature of the payload in the X-Influss-Signature header,
+// which is `sha256=` followed by the hex encoded HMAC-SHA256 of the payload with the secret as key.
+func Sign(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (d *Dispatcher) subscription(url string) (Subscription, bool) {
+	for _, sub := range d.cfg.Subscriptions {
+		if sub.URL == url {
+			return sub, true
+		}
+	}
+	return Subscription{}, false
+}
+
+// prune drops the oldest finished deliveries beyond the ones kept for debugging.
+func (d *Dispatcher) prune() {
+	finished := 0
+	for _, dl := range d.outbox.Deliveries {
+		if dl.Status != StatusPending {
+			finished++
+		}
+	}
+	if finished <= keptDeliveries {
+		return
+	}
+	drop := finished - keptDeliveries
+	d.outbox.Deliveries = slices.DeleteFunc(d.outbox.Deliveries, func(dl *delivery) bool {
+		if drop > 0 && dl.Status != StatusPending {
+			drop--
+			return true
+		}
+		return false
+	})
+}
+
+// save writes the outbox.
+func (d *Dispatcher) save() error {
+	return jsonfile.Write(d.cfg.OutboxFile, d.outbox)
+}
+
+// optionalTime returns nil for the zero time, so that it's left out of the JSON.
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+func newID() string {
+	var id [16]byte
+	rand.Read(id[:])
+	return hex.EncodeToString(id[:])
+}
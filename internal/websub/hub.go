@@ -0,0 +1,293 @@
+package websub
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/timofurrer/influss/internal/jsonfile"
+)
+
+const (
+	defaultLease = 10 * 24 * time.Hour
+	maxLease     = 30 * 24 * time.Hour
+	minLease     = time.Hour
+	// maxSecretLength is the maximum length of a subscription secret as defined by the WebSub spec.
+	maxSecretLength = 199
+)
+
+// ContentFunc renders the feed of the topic.
+type ContentFunc func(ctx context.Context, topic *url.URL) ([]byte, error)
+
+type HubConfig struct {
+	// HubURL is the external URL of the hub.
+	HubURL string
+	// FeedURL is the external URL of the feed, topics are the feed URL with any query parameters.
+	FeedURL string
+	// StateFile persists the subscriptions.
+	StateFile string
+	Content   ContentFunc
+}
+
+// Hub is a minimal WebSub hub for the feed. It verifies the subscriptions
+// and pushes the feed to the subscribers when clips change.
+type Hub struct {
+	*publisher
+	cfg     HubConfig
+	feedURL *url.URL
+	client  *http.Client
+
+	mu   sync.Mutex
+	subs []*subscription
+}
+
+type subscription struct {
+	Callback  string    `json:"callback"`
+	Topic     string    `json:"topic"`
+	Secret    string    `json:"secret,omitempty"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// NewHub creates the hub and loads the subscriptions from the state file.
+func NewHub(log *slog.Logger, cfg HubConfig) (*Hub, error) {
+	feedURL, err := url.Parse(cfg.FeedURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid feed URL: %w", err)
+	}
+	h := &Hub{cfg: cfg, feedURL: feedURL, client: &http.Client{Timeout: 30 * time.Second}}
+	h.publisher = newPublisher(log, h.distribute)
+
+	data, err := os.ReadFile(cfg.StateFile)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return nil, fmt.Errorf("failed to read WebSub subscriptions: %w", err)
+	default:
+		if err := json.Unmarshal(data, &h.subs); err != nil {
+			return nil, fmt.Errorf("failed to parse WebSub subscriptions: %w", err)
+		}
+	}
+	return h, nil
+}
+
+// Register registers the hub endpoint on the given mux.
+func (h *Hub) Register(mux *http.ServeMux) {
+	mux.HandleFunc("POST /websub", h.subscribeFunc)
+}
+
+// subscribeFunc handles subscription and unsubscription requests, which are verified asynchronously.
+func (h *Hub) subscribeFunc(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, fmt.Sprintf("Error parsing request body: %s", err), http.StatusBadRequest)
+		return
+	}
+
+	mode := r.PostForm.Get("hub.mode")
+	if mode != "subscribe" && mode != "unsubscribe" {
+		http.Error(w, fmt.Sprintf("Unsupported hub.mode %q, must be subscribe or unsubscribe", mode), http.StatusBadRequest)
+		return
+	}
+
+	callback, err := url.Parse(r.PostForm.Get("hub.callback"))
+	if err != nil || (callback.Scheme != "http" && callback.Scheme != "https") || callback.Host == "" {
+		http.Error(w, "Invalid hub.callback, must be an absolute HTTP URL", http.StatusBadRequest)
+		return
+	}
+
+	topic := r.PostForm.Get("hub.topic")
+	if !h.validTopic(topic) {
+		http.Error(w, fmt.Sprintf("Unknown hub.topic %q, must be %s", topic, h.cfg.FeedURL), http.StatusBadRequest)
+		return
+	}
+
+	secret := r.PostForm.Get("hub.secret")
+	if len(secret) > maxSecretLength {
+		http.Error(w, "The hub.secret is too long", http.StatusBadRequest)
+		return
+	}
+
+	lease := defaultLease
+	if v := r.PostForm.Get("hub.lease_seconds"); v != "" {
+		seconds, err := strconv.Atoi(v)
+		if err != nil || seconds <= 0 {
+			http.Error(w, fmt.Sprintf("Invalid hub.lease_seconds %q", v), http.StatusBadRequest)
+			return
+		}
+		lease = min(max(time.Duration(seconds)*time.Second, minLease), maxLease)
+	}
+
+	sub := &subscription{Callback: callback.String(), Topic: topic, Secret: secret}
+	go h.verify(context.WithoutCancel(r.Context()), mode, sub, lease)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// validTopic checks that the topic is the feed, possibly with query parameters.
+func (h *Hub) validTopic(topic string) bool {
+	u, err := url.Parse(topic)
+	if err != nil {
+		return false
+	}
+	return u.Scheme == h.feedURL.Scheme && u.Host == h.feedURL.Host && u.Path == h.feedURL.Path
+}
+
+// verify verifies the intent of the subscriber by echoing a challenge from the callback.
+func (h *Hub) verify(ctx context.Context, mode string, sub *subscription, lease time.Duration) {
+	log := h.log.With(slog.String("mode", mode), slog.String("callback", sub.Callback), slog.String("topic", sub.Topic))
+
+	var challenge [16]byte
+	rand.Read(challenge[:])
+	challengeStr := hex.EncodeToString(challenge[:])
+
+	u, _ := url.Parse(sub.Callback)
+	q := u.Query()
+	q.Set("hub.mode", mode)
+	q.Set("hub.topic", sub.Topic)
+	q.Set("hub.challenge", challengeStr)
+	if mode == "subscribe" {
+		q.Set("hub.lease_seconds", strconv.Itoa(int(lease.Seconds())))
+	}
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		log.Warn("Failed to verify WebSub subscription", slog.String("error", err.Error()))
+		return
+	}
+	resp, err := h.client.Do(req)
+	if err != nil {
+		log.Warn("Failed to verify WebSub subscription", slog.String("error", err.Error()))
+		return
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 || string(bytes.TrimSpace(body)) != challengeStr {
+		log.Warn("Subscriber didn't confirm WebSub subscription", slog.Int("status_code", resp.StatusCode))
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.remove(sub.Callback, sub.Topic)
+	if mode == "subscribe" {
+		sub.ExpiresAt = time.Now().Add(lease).UTC()
+		h.subs = append(h.subs, sub)
+	}
+	if err := h.save(); err != nil {
+		log.Error("Failed to save WebSub subscriptions", slog.String("error", err.Error()))
+	}
+	log.Info("Verified WebSub subscription")
+}
+
+// distribute pushes the feed to all subscribers.
+// Failed deliveries aren't retried, because the next change pushes the whole feed again.
+func (h *Hub) distribute(ctx context.Context) {
+	h.mu.Lock()
+	now := time.Now()
+	var active []*subscription
+	expired := false
+	for _, sub := range h.subs {
+		if sub.ExpiresAt.After(now) {
+			active = append(active, sub)
+		} else {
+			expired = true
+		}
+	}
+	if expired {
+		h.subs = active
+		if err := h.save(); err != nil {
+			h.log.Error("Failed to save WebSub subscriptions", slog.String("error", err.Error()))
+		}
+	}
+	h.mu.Unlock()
+
+	contents := make(map[string][]byte)
+	for _, sub := range active {
+		log := h.log.With(slog.String("callback", sub.Callback), slog.String("topic", sub.Topic))
+
+		content, ok := contents[sub.Topic]
+		if !ok {
+			topic, _ := url.Parse(sub.Topic)
+			var err error
+			if content, err = h.cfg.Content(ctx, topic); err != nil {
+				log.Error("Failed to render WebSub topic", slog.String("error", err.Error()))
+				continue
+			}
+			contents[sub.Topic] = content
+		}
+
+		statusCode, err := h.push(ctx, sub, content)
+		if statusCode == http.StatusGone {
+			log.Info("Subscriber is gone, removing WebSub subscription")
+			h.mu.Lock()
+			h.remove(sub.Callback, sub.Topic)
+			if err := h.save(); err != nil {
+				log.Error("Failed to save WebSub subscriptions", slog.String("error", err.Error()))
+			}
+			h.mu.Unlock()
+			continue
+		}
+		if err != nil {
+			log.Warn("Failed to push feed to WebSub subscriber", slog.String("error", err.Error()))
+			continue
+		}
+		log.Info("Pushed feed to WebSub subscriber")
+	}
+}
+
+func (h *Hub) push(ctx context.Context, sub *subscription, content []byte) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.Callback, bytes.NewReader(content))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/rss+xml")
+	req.Header.Add("Link", fmt.Sprintf(`<%s>; rel="hub"`, h.cfg.HubURL))
+	req.Header.Add("Link", fmt.Sprintf(`<%s>; rel="self"`, sub.Topic))
+	if sub.Secret != "" {
+		mac := hmac.New(sha256.New, []byte(sub.Secret))
+		mac.Write(content)
+		req.Header.Set("X-Hub-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<20))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// remove removes the subscription of the callback to the topic, the lock must be held.
+func (h *Hub) remove(callback, topic string) {
+	var subs []*subscription
+	for _, sub := range h.subs {
+		if sub.Callback != callback || sub.Topic != topic {
+			subs = append(subs, sub)
+		}
+	}
+	h.subs = subs
+}
+
+// save writes the subscriptions, the lock must be held.
+func (h *Hub) save() error {
+	return jsonfile.Write(h.cfg.StateFile, h.subs)
+}
@@ -0,0 +1,101 @@
+// Package websub pushes the feed to WebSub subscribers when clips change,
+// either by pinging an external hub or with a minimal embedded hub.
+package websub
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/timofurrer/influss/internal/store"
+)
+
+// publishDelay is how long to wait for further changes before publishing,
+// so that e.g. an import publishes once instead of for every clip.
+const publishDelay = 2 * time.Second
+
+// publisher publishes the feed after clips changed.
+type publisher struct {
+	log     *slog.Logger
+	publish func(ctx context.Context)
+}
+
+func newPublisher(log *slog.Logger, publish func(ctx context.Context)) *publisher {
+	return &publisher{log: log, publish: publish}
+}
+
+// Run publishes the feed shortly after the store events until the context is canceled or the events are closed.
+func (p *publisher) Run(ctx context.Context, events <-chan store.Event) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case _, ok := <-events:
+			if !ok {
+				return
+			}
+		}
+
+		// further changes within the delay are published at once
+		timer := time.After(publishDelay)
+	collect:
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case _, ok := <-events:
+				if !ok {
+					return
+				}
+			case <-timer:
+				break collect
+			}
+		}
+
+		p.publish(ctx)
+	}
+}
+
+// Pinger notifies an external hub that the feed changed, which then fetches the feed and pushes it to the subscribers.
+type Pinger struct {
+	*publisher
+	client *http.Client
+	hubURL string
+	topic  string
+}
+
+// NewPinger creates a pinger which notifies the hub about changes of the topic, the URL of the feed.
+func NewPinger(log *slog.Logger, hubURL, topic string) *Pinger {
+	p := &Pinger{client: &http.Client{Timeout: 30 * time.Second}, hubURL: hubURL, topic: topic}
+	p.publisher = newPublisher(log, p.ping)
+	return p
+}
+
+func (p *Pinger) ping(ctx context.Context) {
+	form := url.Values{"hub.mode": {"publish"}, "hub.url": {p.topic}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.hubURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		p.log.Error("Failed to ping WebSub hub", slog.String("error", err.Error()))
+		return
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		p.log.Error("Failed to ping WebSub hub", slog.String("error", err.Error()))
+		return
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<20))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		p.log.Error("Failed to ping WebSub hub", slog.String("error", fmt.Sprintf("unexpected status code %d", resp.StatusCode)))
+		return
+	}
+	p.log.Info("Pinged WebSub hub", slog.String("hub", p.hubURL), slog.String("topic", p.topic))
+}
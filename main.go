@@ -17,7 +17,10 @@ func main() {
 	err := cmd.Parse()
 	if err != nil {
 		log.Error("failed to parse command line", slog.Any("error", err))
-		return
+		os.Exit(1)
+	}
+	if err := cmd.Run(); err != nil {
+		log.Error("command failed", slog.String("error", err.Error()))
+		os.Exit(1)
 	}
-	cmd.Run()
 }